@@ -1,50 +1,282 @@
 package main
 
 import (
+	"bufio"
 	"code"
+	"code/parsing"
 	"context"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"strings"
 
 	"github.com/urfave/cli/v3"
 )
 
 func GenDiff(_ context.Context, cmd *cli.Command) error {
-	format := cmd.String("format")
 	if cmd.NArg() == 0 {
 		fmt.Println(cmd.Usage)
 		return nil
-	} else if cmd.NArg() == 2 {
-		filepath1 := cmd.Args().Get(0)
-		filepath2 := cmd.Args().Get(1)
-		result, err := code.GenDiff(filepath1, filepath2, format)
+	} else if cmd.NArg() != 2 {
+		return fmt.Errorf("expected 2 arguments, got %d", cmd.NArg())
+	}
+
+	filepath1 := restoreStdinArg(cmd.Args().Get(0))
+	filepath2 := restoreStdinArg(cmd.Args().Get(1))
+	opts, err := optionsFromCommand(cmd)
+	if err != nil {
+		return err
+	}
+	return writeDiff(os.Stdout, filepath1, filepath2, opts)
+}
+
+// optionsFromCommand collects the diff options from the command line flags
+func optionsFromCommand(cmd *cli.Command) (code.Options, error) {
+	opts := code.Options{
+		Format: cmd.String("format"),
+		FormatOptions: code.FormatOptions{
+			Summary:        cmd.Bool("summary"),
+			PruneUnchanged: cmd.Bool("prune-unchanged"),
+			ShowPositions:  cmd.Bool("positions"),
+			SQL: code.SQLOptions{
+				Table:       cmd.String("sql-table"),
+				KeyColumn:   cmd.String("sql-key-column"),
+				ValueColumn: cmd.String("sql-value-column"),
+				Dialect:     cmd.String("sql-dialect"),
+				Transaction: cmd.Bool("sql-transaction"),
+			},
+		},
+		ParseOptions: parsing.Options{
+			Format:         cmd.String("input-format"),
+			LenientJSON:    cmd.Bool("lenient-json"),
+			NestProperties: cmd.Bool("nest-properties"),
+			MultiDocument:  cmd.Bool("multi-doc"),
+			Strict:         cmd.Bool("strict"),
+			Warnings:       os.Stderr,
+			ExpandEnv:      cmd.Bool("expand-env") || cmd.IsSet("env-file"),
+			ResolveRefs:    cmd.Bool("resolve-refs"),
+		},
+		InputFormat1: cmd.String("format1"),
+		InputFormat2: cmd.String("format2"),
+	}
+	if identity := cmd.String("identity"); identity != "" {
+		opts.ParseOptions.Identity = strings.Split(identity, ",")
+	}
+	if cmd.Bool("raw-templates") {
+		opts.ParseOptions.ExpandEnv = false
+	} else if envFile := cmd.String("env-file"); envFile != "" {
+		env, err := parsing.LoadEnvFile(envFile)
 		if err != nil {
+			return code.Options{}, err
+		}
+		opts.ParseOptions.Env = env
+	}
+	if cmd.Bool("stat") {
+		opts.Format = "stat"
+	}
+	if cmd.IsSet("context") {
+		if cmd.Int("context") < 0 {
+			return code.Options{}, fmt.Errorf("context must not be negative, got %d", cmd.Int("context"))
+		}
+		opts.FormatOptions.LimitContext = true
+		opts.FormatOptions.Context = cmd.Int("context")
+	}
+	return opts, nil
+}
+
+// stdinArg stands in for "-" while the command line is parsed, because
+// urfave/cli stops reading arguments at a bare "-" and drops the rest
+const stdinArg = "\x00stdin"
+
+// protectStdinArgs replaces the bare "-" positional arguments with
+// stdinArg. A "-" given as the value of a flag, such as --sql-table -, is
+// left as it is.
+func protectStdinArgs(flags []cli.Flag, args []string) []string {
+	takesValue := map[string]bool{}
+	for _, flag := range flags {
+		if f, ok := flag.(cli.DocGenerationFlag); ok && f.TakesValue() {
+			for _, name := range flag.Names() {
+				takesValue[name] = true
+			}
+		}
+	}
+
+	protected := append([]string{}, args...)
+	positional := false
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == parsing.StdinPath:
+			protected[i] = stdinArg
+		case positional:
+		case arg == "--":
+			positional = true
+		case strings.HasPrefix(arg, "-") && !strings.Contains(arg, "=") && takesValue[strings.TrimLeft(arg, "-")]:
+			i++
+		}
+	}
+	return protected
+}
+
+// restoreStdinArg turns stdinArg back into the path parsing reads stdin for
+func restoreStdinArg(arg string) string {
+	if arg == stdinArg {
+		return parsing.StdinPath
+	}
+	return arg
+}
+
+// writeDiff streams the diff to w through a buffer and terminates it with a
+// newline, unless the diff is empty
+func writeDiff(w io.Writer, filepath1, filepath2 string, opts code.Options) error {
+	out := &countingWriter{w: bufio.NewWriter(w)}
+	if err := code.GenDiffTo(out, filepath1, filepath2, opts); err != nil {
+		return err
+	}
+	if out.n > 0 {
+		if err := out.w.WriteByte('\n'); err != nil {
 			return err
 		}
-		fmt.Println(result)
-	} else {
-		return fmt.Errorf("expected 2 arguments, got %d", cmd.NArg())
 	}
-	return nil
+	return out.w.Flush()
 }
 
-func main() {
-	cmd := &cli.Command{
-		Name:   "gendiff",
-		Usage:  "Compares two configuration files and shows a difference.",
-		Action: GenDiff,
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w *bufio.Writer
+	n int
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += n
+	return n, err
+}
+
+// formatUsage lists the registered output formats in the --format help text
+func formatUsage() string {
+	return fmt.Sprintf("output format (%s)", strings.Join(code.Formatters(), ", "))
+}
+
+// inputFormatUsage describes an input format override flag
+func inputFormatUsage(target string) string {
+	return fmt.Sprintf("input format of %s instead of detecting it (%s)", target, strings.Join(parsing.Formats(), ", "))
+}
+
+// newCommand builds the gendiff command with all of its flags
+func newCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "gendiff",
+		Usage:     "Compares two configuration files and shows a difference.",
+		ArgsUsage: "FILE1 FILE2 (use - to read one of them from stdin and ARCHIVE!PATH for a file in a tar or zip archive)",
+		Action:    GenDiff,
 		Flags: []cli.Flag{
 			&cli.StringFlag{
 				Name:    "format",
 				Value:   "stylish",
-				Usage:   "output format",
+				Usage:   formatUsage(),
 				Aliases: []string{"f"},
 			},
+			&cli.StringFlag{
+				Name:  "input-format",
+				Usage: inputFormatUsage("both files"),
+			},
+			&cli.StringFlag{
+				Name:  "format1",
+				Usage: inputFormatUsage("the first file"),
+			},
+			&cli.StringFlag{
+				Name:  "format2",
+				Usage: inputFormatUsage("the second file"),
+			},
+			&cli.BoolFlag{
+				Name:  "lenient-json",
+				Usage: "accept comments, trailing commas, single quotes and unquoted keys in .json files",
+			},
+			&cli.BoolFlag{
+				Name:  "nest-properties",
+				Usage: "turn dotted keys of .properties files into nested maps",
+			},
+			&cli.BoolFlag{
+				Name:  "multi-doc",
+				Usage: "pair YAML documents by identity even when a file holds a single document",
+			},
+			&cli.BoolFlag{
+				Name:  "strict",
+				Usage: "fail on duplicate keys instead of printing warnings",
+			},
+			&cli.BoolFlag{
+				Name:  "expand-env",
+				Usage: "replace ${VAR}, ${VAR:-default} and ${VAR:?message} placeholders with environment variables",
+			},
+			&cli.StringFlag{
+				Name:  "env-file",
+				Usage: "expand placeholders with the variables of the dotenv `FILE` instead of the environment",
+			},
+			&cli.BoolFlag{
+				Name:  "raw-templates",
+				Usage: "compare placeholders as written, overriding --expand-env and --env-file",
+			},
+			&cli.BoolFlag{
+				Name:  "resolve-refs",
+				Usage: "replace $ref objects and !include tags by the files they point to (--positions then shows where each value comes from)",
+			},
+			&cli.StringFlag{
+				Name:  "identity",
+				Usage: "comma-separated dotted `PATHS` identifying YAML documents (default: apiVersion,kind,metadata.namespace,metadata.name)",
+			},
+			&cli.IntFlag{
+				Name:  "context",
+				Usage: "show only changes and up to `N` unchanged keys around each of them",
+			},
+			&cli.BoolFlag{
+				Name:  "positions",
+				Usage: "show the file, line and column of every key (json and yaml files only)",
+			},
+			&cli.BoolFlag{
+				Name:  "prune-unchanged",
+				Usage: "leave unchanged subtrees out of the dot output",
+			},
+			&cli.StringFlag{
+				Name:  "sql-table",
+				Value: "config",
+				Usage: "table updated by the sql output",
+			},
+			&cli.StringFlag{
+				Name:  "sql-key-column",
+				Value: "key",
+				Usage: "key column used by the sql output",
+			},
+			&cli.StringFlag{
+				Name:  "sql-value-column",
+				Value: "value",
+				Usage: "value column used by the sql output",
+			},
+			&cli.StringFlag{
+				Name:  "sql-dialect",
+				Value: "ansi",
+				Usage: "quoting rules of the sql output (ansi, postgres, sqlite, mysql)",
+			},
+			&cli.BoolFlag{
+				Name:  "sql-transaction",
+				Usage: "wrap the sql output in a transaction",
+			},
+			&cli.BoolFlag{
+				Name:  "stat",
+				Usage: "print only diff statistics (same as --format stat)",
+			},
+			&cli.BoolFlag{
+				Name:  "summary",
+				Usage: "append diff statistics under the output (as comments in the sql, jq and dot formats)",
+			},
 		},
 	}
+}
 
-	if err := cmd.Run(context.Background(), os.Args); err != nil {
+func main() {
+	cmd := newCommand()
+	if err := cmd.Run(context.Background(), protectStdinArgs(cmd.Flags, os.Args)); err != nil {
 		log.Fatal(err)
 	}
 }
@@ -2,8 +2,10 @@ package main
 
 import (
 	"bytes"
+	"code"
 	"code/helpers"
 	"context"
+	"errors"
 	"io"
 	"os"
 	"testing"
@@ -317,3 +319,185 @@ func TestGenDiffEdgeCases(t *testing.T) {
 		})
 	}
 }
+
+// failingWriter fails every write with errWrite
+type failingWriter struct{}
+
+var errWrite = errors.New("write failed")
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errWrite
+}
+
+func TestWriteDiff(t *testing.T) {
+	file1 := helpers.CreateTempJSON(t, `{"key": "old"}`)
+	file2 := helpers.CreateTempJSON(t, `{"key": "new"}`)
+	opts := code.Options{Format: "stylish"}
+
+	var buf bytes.Buffer
+	require.NoError(t, writeDiff(&buf, file1, file2, opts))
+	assert.Equal(t, "{\n  - key: old\n  + key: new\n}\n", buf.String())
+
+	err := writeDiff(failingWriter{}, file1, file2, opts)
+	assert.ErrorIs(t, err, errWrite)
+
+	for _, format := range []string{"name-only", "name-status"} {
+		buf.Reset()
+		require.NoError(t, writeDiff(&buf, file1, file1, code.Options{Format: format}))
+		assert.Empty(t, buf.String(), format)
+	}
+}
+
+func TestOptionsFromCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		check   func(t *testing.T, opts code.Options)
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+			args: []string{},
+			check: func(t *testing.T, opts code.Options) {
+				assert.Equal(t, "stylish", opts.Format)
+				assert.False(t, opts.FormatOptions.Summary)
+				assert.False(t, opts.FormatOptions.LimitContext)
+				assert.Equal(t, code.SQLOptions{Table: "config", KeyColumn: "key", ValueColumn: "value", Dialect: "ansi"},
+					opts.FormatOptions.SQL)
+			},
+		},
+		{
+			name: "stat overrides format",
+			args: []string{"--stat", "--summary"},
+			check: func(t *testing.T, opts code.Options) {
+				assert.Equal(t, "stat", opts.Format)
+				assert.True(t, opts.FormatOptions.Summary)
+			},
+		},
+		{
+			name: "strict mode",
+			args: []string{"--strict"},
+			check: func(t *testing.T, opts code.Options) {
+				assert.True(t, opts.ParseOptions.Strict)
+			},
+		},
+		{
+			name: "env file",
+			args: []string{"--env-file", helpers.CreateTempFile(t, "*.env", "HOST=db\n")},
+			check: func(t *testing.T, opts code.Options) {
+				assert.True(t, opts.ParseOptions.ExpandEnv)
+				assert.Equal(t, map[string]string{"HOST": "db"}, opts.ParseOptions.Env)
+			},
+		},
+		{
+			name: "resolve references",
+			args: []string{"--resolve-refs"},
+			check: func(t *testing.T, opts code.Options) {
+				assert.True(t, opts.ParseOptions.ResolveRefs)
+			},
+		},
+		{
+			name: "raw templates",
+			args: []string{"--expand-env", "--raw-templates"},
+			check: func(t *testing.T, opts code.Options) {
+				assert.False(t, opts.ParseOptions.ExpandEnv)
+			},
+		},
+		{
+			name:    "missing env file",
+			args:    []string{"--env-file", "nonexistent.env"},
+			wantErr: true,
+		},
+		{
+			name: "context limit",
+			args: []string{"--context", "2"},
+			check: func(t *testing.T, opts code.Options) {
+				assert.True(t, opts.FormatOptions.LimitContext)
+				assert.Equal(t, 2, opts.FormatOptions.Context)
+			},
+		},
+		{
+			name: "input format overrides",
+			args: []string{"--input-format", "yaml", "--format2", "json", "--identity", "kind,metadata.name"},
+			check: func(t *testing.T, opts code.Options) {
+				assert.Equal(t, "yaml", opts.ParseOptions.Format)
+				assert.Empty(t, opts.InputFormat1)
+				assert.Equal(t, "json", opts.InputFormat2)
+				assert.Equal(t, []string{"kind", "metadata.name"}, opts.ParseOptions.Identity)
+			},
+		},
+		{
+			name:    "negative context",
+			args:    []string{"--context", "-1"},
+			wantErr: true,
+		},
+		{
+			name: "sql settings",
+			args: []string{"-f", "sql", "--sql-table", "settings", "--sql-dialect", "mysql", "--sql-transaction"},
+			check: func(t *testing.T, opts code.Options) {
+				assert.Equal(t, "sql", opts.Format)
+				assert.Equal(t, "settings", opts.FormatOptions.SQL.Table)
+				assert.Equal(t, "mysql", opts.FormatOptions.SQL.Dialect)
+				assert.True(t, opts.FormatOptions.SQL.Transaction)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got code.Options
+			var gotErr error
+			cmd := newCommand()
+			cmd.Action = func(_ context.Context, c *cli.Command) error {
+				got, gotErr = optionsFromCommand(c)
+				return nil
+			}
+
+			require.NoError(t, cmd.Run(context.Background(), append([]string{"gendiff"}, tt.args...)))
+			if tt.wantErr {
+				require.Error(t, gotErr)
+				return
+			}
+			require.NoError(t, gotErr)
+			tt.check(t, got)
+		})
+	}
+}
+
+func TestGenDiffFromStdin(t *testing.T) {
+	oldStdin := os.Stdin
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	os.Stdin = r
+	defer func() {
+		os.Stdin = oldStdin
+		require.NoError(t, r.Close())
+	}()
+
+	_, err = w.WriteString("key: old\n")
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	file2 := helpers.CreateTempJSON(t, `{"key": "new"}`)
+	var got code.Options
+	var gotArgs []string
+	cmd := newCommand()
+	cmd.Action = func(_ context.Context, c *cli.Command) error {
+		got, _ = optionsFromCommand(c)
+		gotArgs = []string{restoreStdinArg(c.Args().Get(0)), restoreStdinArg(c.Args().Get(1))}
+		return nil
+	}
+
+	args := protectStdinArgs(cmd.Flags, []string{"gendiff", "--sql-table", "-", "-", file2, "--summary"})
+	require.NoError(t, cmd.Run(context.Background(), args))
+	assert.Equal(t, []string{"-", file2}, gotArgs)
+	assert.True(t, got.FormatOptions.Summary)
+	assert.Equal(t, "-", got.FormatOptions.SQL.Table)
+
+	var buf bytes.Buffer
+	require.NoError(t, writeDiff(&buf, gotArgs[0], gotArgs[1], code.Options{Format: "stylish"}))
+	assert.Equal(t, "{\n  - key: old\n  + key: new\n}\n", buf.String())
+
+	assert.Equal(t, []string{"gendiff", "--env-file", "-", "-f=json", stdinArg, "--", stdinArg},
+		protectStdinArgs(cmd.Flags, []string{"gendiff", "--env-file", "-", "-f=json", "-", "--", "-"}))
+}
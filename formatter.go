@@ -1,7 +1,12 @@
 package code
 
 import (
+	"code/parsing"
 	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
 )
 
 // Formatter defines the interface for different output formats
@@ -9,12 +14,75 @@ type Formatter interface {
 	Format(diff []DiffEntry) string
 }
 
-// DiffEntry represents a single difference between two files
+// StreamFormatter is implemented by formatters that can write their output
+// incrementally instead of building it in memory first
+type StreamFormatter interface {
+	Formatter
+	FormatTo(w io.Writer, diff []DiffEntry) error
+}
+
+// validator is implemented by formatters whose options can be invalid, so
+// that NewFormatterWithOptions reports them instead of Format
+type validator interface {
+	validate() error
+}
+
+// FormatOptions holds settings passed to a formatter factory.
+// Formatters ignore the fields they do not support.
+type FormatOptions struct {
+	// Summary appends diff statistics under the formatted output, as
+	// comments in the sql, jq and dot formats
+	Summary bool
+	// LimitContext hides unchanged keys that are more than Context
+	// siblings away from a change
+	LimitContext bool
+	Context      int
+	// PruneUnchanged leaves entries without differences out of graph output
+	PruneUnchanged bool
+	// SQL configures the sql format
+	SQL SQLOptions
+	// ShowPositions prints where each key is found in the compared files
+	ShowPositions bool
+}
+
+// FormatterFactory creates a formatter configured with the given options
+type FormatterFactory func(opts FormatOptions) Formatter
+
+// DiffEntry represents a single difference between two files.
+// Entries with StatusNested carry the differences of both maps in Children.
+// OldPos and NewPos locate the key in each file when the format records
+// positions and the key exists there. Documents whose root is not an object
+// are compared as a whole and give a single entry with Root set and no Key.
 type DiffEntry struct {
-	Key    string
-	Status DiffStatus
-	OldVal interface{}
-	NewVal interface{}
+	Key      string
+	Status   DiffStatus
+	OldVal   interface{}
+	NewVal   interface{}
+	Children []DiffEntry
+	OldPos   parsing.Position
+	NewPos   parsing.Position
+	Root     bool
+}
+
+// HasChanges reports whether the entry or any of its children differ
+func (e DiffEntry) HasChanges() bool {
+	if e.Status != StatusNested {
+		return e.Status != StatusUnchanged
+	}
+	for _, child := range e.Children {
+		if child.HasChanges() {
+			return true
+		}
+	}
+	return false
+}
+
+// path returns the keys leading to the entry from the root of the document
+func (e DiffEntry) path(parent []string) []string {
+	if e.Root {
+		return []string{}
+	}
+	return append(parent[:len(parent):len(parent)], e.Key)
 }
 
 // DiffStatus represents the type of difference
@@ -25,14 +93,187 @@ const (
 	StatusAdded                       // Key only exists in file2
 	StatusRemoved                     // Key only exists in file1
 	StatusChanged                     // Key exists in both with different values
+	StatusNested                      // Key holds a map in both files, see Children
 )
 
+var (
+	formattersMu sync.RWMutex
+	formatters   = map[string]FormatterFactory{
+		"stylish": func(opts FormatOptions) Formatter {
+			return &FormatterStylish{
+				LimitContext:  opts.LimitContext,
+				Context:       opts.Context,
+				ShowPositions: opts.ShowPositions,
+			}
+		},
+		"stat": func(FormatOptions) Formatter { return &FormatterStat{} },
+		"name-only": func(opts FormatOptions) Formatter {
+			return &FormatterNames{ShowPositions: opts.ShowPositions}
+		},
+		"name-status": func(opts FormatOptions) Formatter {
+			return &FormatterNames{Status: true, ShowPositions: opts.ShowPositions}
+		},
+		"dot": func(opts FormatOptions) Formatter {
+			return &FormatterDOT{PruneUnchanged: opts.PruneUnchanged}
+		},
+		"sql": func(opts FormatOptions) Formatter { return &FormatterSQL{Options: opts.SQL} },
+		"jq":  func(FormatOptions) Formatter { return &FormatterJQ{} },
+	}
+)
+
+// RegisterFormatter makes a formatter available under the given name.
+// It panics if the name is empty, the factory is nil or the name is already taken.
+func RegisterFormatter(name string, factory func(opts FormatOptions) Formatter) {
+	formattersMu.Lock()
+	defer formattersMu.Unlock()
+
+	if name == "" {
+		panic("code: RegisterFormatter name is empty")
+	}
+	if factory == nil {
+		panic("code: RegisterFormatter factory is nil for " + name)
+	}
+	if _, exists := formatters[name]; exists {
+		panic("code: RegisterFormatter called twice for " + name)
+	}
+	formatters[name] = factory
+}
+
+// Formatters returns the sorted names of all registered formatters
+func Formatters() []string {
+	formattersMu.RLock()
+	defer formattersMu.RUnlock()
+
+	names := make([]string, 0, len(formatters))
+	for name := range formatters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // NewFormatter creates a formatter based on the format name
 func NewFormatter(format string) (Formatter, error) {
-	switch format {
-	case "stylish":
-		return &FormatterStylish{}, nil
-	default:
+	return NewFormatterWithOptions(format, FormatOptions{})
+}
+
+// NewFormatterWithOptions creates a registered formatter and passes opts to its factory
+func NewFormatterWithOptions(format string, opts FormatOptions) (Formatter, error) {
+	formattersMu.RLock()
+	factory, ok := formatters[format]
+	formattersMu.RUnlock()
+
+	if !ok {
 		return nil, fmt.Errorf("unsupported format: %s", format)
 	}
+
+	formatter := factory(opts)
+	if v, ok := formatter.(validator); ok {
+		if err := v.validate(); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Summary && format != "stat" {
+		formatter = &summaryFormatter{inner: formatter, comment: summaryComments[format]}
+	}
+	return formatter, nil
+}
+
+// WriteDiff writes the formatted diff to w. Formatters implementing
+// StreamFormatter write directly, others are formatted to a string first.
+func WriteDiff(w io.Writer, f Formatter, diff []DiffEntry) error {
+	if sf, ok := f.(StreamFormatter); ok {
+		return sf.FormatTo(w, diff)
+	}
+	_, err := io.WriteString(w, f.Format(diff))
+	return err
+}
+
+// errWriter remembers the first write error so that formatters can check it once
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (ew *errWriter) printf(format string, args ...interface{}) {
+	if ew.err != nil {
+		return
+	}
+	_, ew.err = fmt.Fprintf(ew.w, format, args...)
+}
+
+// walkChanges calls fn for every changed entry that is not nested,
+// passing the keys leading to it from the root
+func walkChanges(diff []DiffEntry, path []string, fn func(path []string, entry DiffEntry)) {
+	for _, entry := range diff {
+		entryPath := entry.path(path)
+		switch entry.Status {
+		case StatusNested:
+			walkChanges(entry.Children, entryPath, fn)
+		case StatusAdded, StatusRemoved, StatusChanged:
+			fn(entryPath, entry)
+		}
+	}
+}
+
+// entryPositions returns the valid positions of an entry, old one first
+func entryPositions(entry DiffEntry) []string {
+	var positions []string
+	for _, pos := range []parsing.Position{entry.OldPos, entry.NewPos} {
+		if pos.IsValid() {
+			positions = append(positions, pos.String())
+		}
+	}
+	return positions
+}
+
+// formatValue renders a value like %v does, except that numbers are
+// written with parsing.FormatNumber and nested values are rendered alike
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case float64:
+		return parsing.FormatNumber(v)
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = formatValue(item)
+		}
+		return "[" + strings.Join(items, " ") + "]"
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		items := make([]string, len(keys))
+		for i, k := range keys {
+			items[i] = k + ":" + formatValue(v[k])
+		}
+		return "map[" + strings.Join(items, " ") + "]"
+	case parsing.TaggedValue:
+		return v.Tag + " " + formatValue(v.Value)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// valueKind names the JSON type of a parsed value, preceded by the tag of
+// a YAML TaggedValue
+func valueKind(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "bool"
+	case string:
+		return "string"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case parsing.TaggedValue:
+		return v.Tag + " " + valueKind(v.Value)
+	default:
+		return "number"
+	}
 }
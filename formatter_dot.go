@@ -0,0 +1,134 @@
+package code
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// dotColors maps each status to the fill colour of its nodes
+var dotColors = map[DiffStatus]string{
+	StatusUnchanged: "white",
+	StatusAdded:     "palegreen",
+	StatusRemoved:   "lightpink",
+	StatusChanged:   "khaki",
+	StatusNested:    "lightblue",
+}
+
+// FormatterDOT renders the diff tree as a Graphviz digraph. Nodes are
+// coloured by status; with PruneUnchanged set, entries without any
+// difference are left out of the graph.
+type FormatterDOT struct {
+	PruneUnchanged bool
+}
+
+func (f *FormatterDOT) Format(diff []DiffEntry) string {
+	var result strings.Builder
+	_ = f.FormatTo(&result, diff)
+	return result.String()
+}
+
+func (f *FormatterDOT) FormatTo(w io.Writer, diff []DiffEntry) error {
+	g := &dotGraph{out: &errWriter{w: w}, prune: f.PruneUnchanged}
+	g.out.printf("digraph diff {\n")
+	g.out.printf("  node [shape=box, style=filled];\n")
+	root := g.node("/", "white")
+	g.writeEntries(root, diff)
+	g.out.printf("}")
+	return g.out.err
+}
+
+// dotGraph tracks the state needed while writing one graph
+type dotGraph struct {
+	out   *errWriter
+	prune bool
+	nodes int
+}
+
+// node writes a new node and returns its identifier
+func (g *dotGraph) node(label, color string) string {
+	id := fmt.Sprintf("n%d", g.nodes)
+	g.nodes++
+	g.out.printf("  %s [label=%s, fillcolor=%s];\n", id, dotQuote(label), color)
+	return id
+}
+
+// edge connects a parent node to a child node
+func (g *dotGraph) edge(from, to string) {
+	g.out.printf("  %s -> %s;\n", from, to)
+}
+
+func (g *dotGraph) writeEntries(parent string, diff []DiffEntry) {
+	for _, entry := range diff {
+		if g.prune && !entry.HasChanges() {
+			continue
+		}
+
+		switch entry.Status {
+		case StatusAdded:
+			g.writeEntryValue(parent, entry, entry.NewVal)
+		case StatusRemoved, StatusUnchanged:
+			g.writeEntryValue(parent, entry, entry.OldVal)
+		case StatusChanged:
+			label := fmt.Sprintf("- %s\n+ %s", dotValue(entry.OldVal), dotValue(entry.NewVal))
+			if !entry.Root {
+				label = entry.Key + "\n" + label
+			}
+			g.edge(parent, g.node(label, dotColors[StatusChanged]))
+		case StatusNested:
+			color := dotColors[StatusNested]
+			if !entry.HasChanges() {
+				color = dotColors[StatusUnchanged]
+			}
+			id := g.node(entry.Key, color)
+			g.edge(parent, id)
+			g.writeEntries(id, entry.Children)
+		}
+	}
+}
+
+// writeEntryValue writes the value of an added, removed or unchanged entry.
+// Root entries have no key and their value is never an object.
+func (g *dotGraph) writeEntryValue(parent string, entry DiffEntry, value interface{}) {
+	if entry.Root {
+		g.edge(parent, g.node(dotValue(value), dotColors[entry.Status]))
+		return
+	}
+	g.writeValue(parent, entry.Key, value, dotColors[entry.Status])
+}
+
+// writeValue writes a key with its value, expanding maps into subtrees of the same colour
+func (g *dotGraph) writeValue(parent, key string, value interface{}, color string) {
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		g.edge(parent, g.node(fmt.Sprintf("%s: %s", key, dotValue(value)), color))
+		return
+	}
+
+	id := g.node(key, color)
+	g.edge(parent, id)
+
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		g.writeValue(id, k, m[k], color)
+	}
+}
+
+// dotValue renders a leaf value for a node label
+func dotValue(value interface{}) string {
+	if value == nil {
+		return "null"
+	}
+	return formatValue(value)
+}
+
+// dotQuote quotes a label as a DOT string with escaped quotes and line breaks
+func dotQuote(label string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + replacer.Replace(label) + `"`
+}
@@ -0,0 +1,63 @@
+package code
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+// FormatterJQ emits a jq filter made of setpath and delpaths calls.
+// Running the filter on file1 produces file2.
+type FormatterJQ struct{}
+
+func (f *FormatterJQ) Format(diff []DiffEntry) string {
+	var result strings.Builder
+	_ = f.FormatTo(&result, diff)
+	return result.String()
+}
+
+func (f *FormatterJQ) FormatTo(w io.Writer, diff []DiffEntry) error {
+	var stages []string
+	var encodeErr error
+	walkChanges(diff, nil, func(path []string, entry DiffEntry) {
+		if encodeErr != nil {
+			return
+		}
+		jqPath, err := jqJSON(path)
+		if err != nil {
+			encodeErr = err
+			return
+		}
+		if entry.Status == StatusRemoved {
+			stages = append(stages, "delpaths(["+jqPath+"])")
+			return
+		}
+		value, err := jqJSON(entry.NewVal)
+		if err != nil {
+			encodeErr = err
+			return
+		}
+		stages = append(stages, "setpath("+jqPath+"; "+value+")")
+	})
+	if encodeErr != nil {
+		return encodeErr
+	}
+
+	if len(stages) == 0 {
+		stages = append(stages, ".")
+	}
+	_, err := io.WriteString(w, strings.Join(stages, "\n| "))
+	return err
+}
+
+// jqJSON encodes a value as compact JSON without HTML escaping
+func jqJSON(value interface{}) (string, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
@@ -0,0 +1,62 @@
+package code
+
+import (
+	"io"
+	"strings"
+)
+
+// FormatterNames prints one changed path per line, like git diff --name-only.
+// With Status set each path is prefixed by a letter as in --name-status:
+// A (added), D (deleted), M (modified) or T (type changed). ShowPositions
+// appends the positions of the key in both files, separated by tabs.
+type FormatterNames struct {
+	Status        bool
+	ShowPositions bool
+}
+
+func (f *FormatterNames) Format(diff []DiffEntry) string {
+	var result strings.Builder
+	_ = f.FormatTo(&result, diff)
+	return result.String()
+}
+
+func (f *FormatterNames) FormatTo(w io.Writer, diff []DiffEntry) error {
+	out := &errWriter{w: w}
+	separator := ""
+	walkChanges(diff, nil, func(path []string, entry DiffEntry) {
+		out.printf("%s", separator)
+		separator = "\n"
+		if f.Status {
+			out.printf("%s\t", nameStatus(entry))
+		}
+		out.printf("%s", namePath(path))
+		if f.ShowPositions {
+			for _, pos := range entryPositions(entry) {
+				out.printf("\t%s", pos)
+			}
+		}
+	})
+	return out.err
+}
+
+// namePath joins the keys of a path with dots, "." standing for the root
+func namePath(path []string) string {
+	if len(path) == 0 {
+		return "."
+	}
+	return strings.Join(path, ".")
+}
+
+// nameStatus returns the git-style status letter of a changed entry
+func nameStatus(entry DiffEntry) string {
+	switch {
+	case entry.Status == StatusAdded:
+		return "A"
+	case entry.Status == StatusRemoved:
+		return "D"
+	case valueKind(entry.OldVal) != valueKind(entry.NewVal):
+		return "T"
+	default:
+		return "M"
+	}
+}
@@ -0,0 +1,183 @@
+package code
+
+import (
+	"code/parsing"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SQLOptions configures the sql format
+type SQLOptions struct {
+	Table       string // Table holding the configuration, "config" by default
+	KeyColumn   string // Column with the flattened key, "key" by default
+	ValueColumn string // Column with the value, "value" by default
+	Dialect     string // ansi, postgres, sqlite or mysql; ansi by default
+	Transaction bool   // Wrap the statements in a transaction
+}
+
+// FormatterSQL emits the INSERT, UPDATE and DELETE statements that migrate
+// a (key, value) table from file1 to file2. Nested keys are flattened into
+// dotted paths, arrays and empty maps are stored as JSON text and YAML
+// tags are left out. Roots that are not objects have no key to store and
+// are reported as an error.
+type FormatterSQL struct {
+	Options SQLOptions
+}
+
+// Format returns the statements, or a comment holding the error when the
+// diff cannot be written as SQL
+func (f *FormatterSQL) Format(diff []DiffEntry) string {
+	var result strings.Builder
+	if err := f.FormatTo(&result, diff); err != nil {
+		return "-- error: " + err.Error()
+	}
+	return result.String()
+}
+
+// validate checks the options before anything is formatted
+func (f *FormatterSQL) validate() error {
+	if _, ok := sqlDialects[valueOr(f.Options.Dialect, "ansi")]; !ok {
+		return fmt.Errorf("unsupported sql dialect: %s", f.Options.Dialect)
+	}
+	return nil
+}
+
+func (f *FormatterSQL) FormatTo(w io.Writer, diff []DiffEntry) error {
+	if err := f.validate(); err != nil {
+		return err
+	}
+	dialect := sqlDialects[valueOr(f.Options.Dialect, "ansi")]
+	if len(diff) == 1 && diff[0].Root {
+		return fmt.Errorf("sql format needs both files to be objects")
+	}
+	table := dialect.ident(valueOr(f.Options.Table, "config"))
+	keyColumn := dialect.ident(valueOr(f.Options.KeyColumn, "key"))
+	valueColumn := dialect.ident(valueOr(f.Options.ValueColumn, "value"))
+
+	out := &errWriter{w: w}
+	statements := 0
+	statement := func(format string, args ...interface{}) {
+		if statements == 0 && f.Options.Transaction {
+			out.printf("%s;\n", dialect.begin)
+		} else if statements > 0 {
+			out.printf("\n")
+		}
+		statements++
+		out.printf(format, args...)
+	}
+	insert := func(key string, value interface{}) {
+		statement("INSERT INTO %s (%s, %s) VALUES (%s, %s);",
+			table, keyColumn, valueColumn, dialect.literal(key), dialect.value(value))
+	}
+	remove := func(key string, _ interface{}) {
+		statement("DELETE FROM %s WHERE %s = %s;", table, keyColumn, dialect.literal(key))
+	}
+
+	walkChanges(diff, nil, func(path []string, entry DiffEntry) {
+		switch {
+		case entry.Status == StatusAdded:
+			flattenLeaves(path, entry.NewVal, insert)
+		case entry.Status == StatusRemoved:
+			flattenLeaves(path, entry.OldVal, remove)
+		case isMap(untagged(entry.OldVal)) || isMap(untagged(entry.NewVal)):
+			flattenLeaves(path, entry.OldVal, remove)
+			flattenLeaves(path, entry.NewVal, insert)
+		default:
+			statement("UPDATE %s SET %s = %s WHERE %s = %s;", table, valueColumn,
+				dialect.value(entry.NewVal), keyColumn, dialect.literal(strings.Join(path, ".")))
+		}
+	})
+
+	if statements > 0 && f.Options.Transaction {
+		out.printf("\n%s;", dialect.commit)
+	}
+	return out.err
+}
+
+// sqlDialect describes the quoting rules of one SQL dialect
+type sqlDialect struct {
+	identQuote      string
+	escapeBackslash bool
+	begin, commit   string
+}
+
+var sqlDialects = map[string]sqlDialect{
+	"ansi":     {identQuote: `"`, begin: "BEGIN", commit: "COMMIT"},
+	"postgres": {identQuote: `"`, begin: "BEGIN", commit: "COMMIT"},
+	"sqlite":   {identQuote: `"`, begin: "BEGIN TRANSACTION", commit: "COMMIT"},
+	"mysql":    {identQuote: "`", escapeBackslash: true, begin: "START TRANSACTION", commit: "COMMIT"},
+}
+
+// ident quotes a table or column name
+func (d sqlDialect) ident(name string) string {
+	return d.identQuote + strings.ReplaceAll(name, d.identQuote, d.identQuote+d.identQuote) + d.identQuote
+}
+
+// literal quotes a string literal
+func (d sqlDialect) literal(s string) string {
+	if d.escapeBackslash {
+		s = strings.ReplaceAll(s, `\`, `\\`)
+	}
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// value renders a leaf value as a text literal or NULL
+func (d sqlDialect) value(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		return d.literal(v)
+	case float64:
+		return d.literal(strconv.FormatFloat(v, 'f', -1, 64))
+	case parsing.TaggedValue:
+		return d.value(v.Value)
+	case []interface{}, map[string]interface{}:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return d.literal(fmt.Sprintf("%v", v))
+		}
+		return d.literal(string(encoded))
+	default:
+		return d.literal(fmt.Sprintf("%v", v))
+	}
+}
+
+// flattenLeaves calls fn with the dotted key of every leaf inside value.
+// Empty maps are treated as leaves and YAML tags are dropped.
+func flattenLeaves(path []string, value interface{}, fn func(key string, value interface{})) {
+	m, ok := untagged(value).(map[string]interface{})
+	if !ok || len(m) == 0 {
+		fn(strings.Join(path, "."), value)
+		return
+	}
+
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		flattenLeaves(append(path[:len(path):len(path)], k), m[k], fn)
+	}
+}
+
+// untagged returns the value of a TaggedValue, and any other value as is
+func untagged(value interface{}) interface{} {
+	if tagged, ok := value.(parsing.TaggedValue); ok {
+		return tagged.Value
+	}
+	return value
+}
+
+// valueOr returns value, or fallback when value is empty
+func valueOr(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
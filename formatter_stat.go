@@ -0,0 +1,19 @@
+package code
+
+import (
+	"io"
+	"strings"
+)
+
+// FormatterStat prints only the statistics of a diff
+type FormatterStat struct{}
+
+func (f *FormatterStat) Format(diff []DiffEntry) string {
+	var result strings.Builder
+	_ = f.FormatTo(&result, diff)
+	return result.String()
+}
+
+func (f *FormatterStat) FormatTo(w io.Writer, diff []DiffEntry) error {
+	return writeStats(w, ComputeStats(diff), "")
+}
@@ -1,31 +1,178 @@
 package code
 
 import (
+	"code/parsing"
 	"fmt"
+	"io"
+	"sort"
 	"strings"
 )
 
-// FormatterStylish implements the stylish format
-type FormatterStylish struct{}
+// stylishIndent is the width of one nesting level in the stylish format
+const stylishIndent = 4
+
+// FormatterStylish implements the stylish format. When LimitContext is set,
+// only changes and up to Context unchanged siblings around each of them are
+// printed; longer runs of unchanged keys collapse into a single marker line.
+// ShowPositions ends the line of every key with a comment giving its
+// position in the file the line comes from. Documents whose root is not an
+// object are printed as marked values without the enclosing braces.
+type FormatterStylish struct {
+	LimitContext  bool
+	Context       int
+	ShowPositions bool
+}
 
 func (f *FormatterStylish) Format(diff []DiffEntry) string {
 	var result strings.Builder
-	result.WriteString("{\n")
+	_ = f.FormatTo(&result, diff)
+	return result.String()
+}
+
+func (f *FormatterStylish) FormatTo(w io.Writer, diff []DiffEntry) error {
+	out := &errWriter{w: w}
+	if len(diff) == 1 && diff[0].Root {
+		f.writeRoot(out, diff[0])
+		return out.err
+	}
+	out.printf("{\n")
+	f.writeEntries(out, diff, 1)
+	out.printf("}")
+	return out.err
+}
+
+// writeEntries writes the entries of one nesting level
+func (f *FormatterStylish) writeEntries(out *errWriter, diff []DiffEntry, depth int) {
+	visible := f.visibleEntries(diff)
+	hidden := 0
+	for i, entry := range diff {
+		if !visible[i] {
+			hidden++
+			continue
+		}
+		f.writeCollapsed(out, depth, hidden)
+		hidden = 0
 
-	for _, entry := range diff {
 		switch entry.Status {
 		case StatusAdded:
-			result.WriteString(fmt.Sprintf("  + %s: %v\n", entry.Key, entry.NewVal))
+			f.writeLine(out, depth, "+", entry.Key, entry.NewVal, entry.NewPos)
 		case StatusRemoved:
-			result.WriteString(fmt.Sprintf("  - %s: %v\n", entry.Key, entry.OldVal))
+			f.writeLine(out, depth, "-", entry.Key, entry.OldVal, entry.OldPos)
 		case StatusChanged:
-			result.WriteString(fmt.Sprintf("  - %s: %v\n", entry.Key, entry.OldVal))
-			result.WriteString(fmt.Sprintf("  + %s: %v\n", entry.Key, entry.NewVal))
+			f.writeLine(out, depth, "-", entry.Key, entry.OldVal, entry.OldPos)
+			f.writeLine(out, depth, "+", entry.Key, entry.NewVal, entry.NewPos)
 		case StatusUnchanged:
-			result.WriteString(fmt.Sprintf("    %s: %v\n", entry.Key, entry.OldVal))
+			f.writeLine(out, depth, " ", entry.Key, entry.OldVal, entry.OldPos)
+		case StatusNested:
+			out.printf("%s  %s: {%s\n", markerIndent(depth), entry.Key, f.positionComment(entry.OldPos))
+			f.writeEntries(out, entry.Children, depth+1)
+			out.printf("%s}\n", strings.Repeat(" ", depth*stylishIndent))
 		}
 	}
+	f.writeCollapsed(out, depth, hidden)
+}
+
+// visibleEntries marks the entries to print: every change and, when context
+// is limited, the unchanged entries at most Context positions away from one
+func (f *FormatterStylish) visibleEntries(diff []DiffEntry) []bool {
+	visible := make([]bool, len(diff))
+	for i, entry := range diff {
+		if !f.LimitContext {
+			visible[i] = true
+			continue
+		}
+		if !entry.HasChanges() {
+			continue
+		}
+		from := max(i-f.Context, 0)
+		to := min(i+f.Context, len(diff)-1)
+		for j := from; j <= to; j++ {
+			visible[j] = true
+		}
+	}
+	return visible
+}
+
+// writeCollapsed writes the marker standing in for a run of hidden unchanged keys
+func (f *FormatterStylish) writeCollapsed(out *errWriter, depth, hidden int) {
+	switch {
+	case hidden == 1:
+		out.printf("%s... 1 unchanged key ...\n", strings.Repeat(" ", depth*stylishIndent))
+	case hidden > 1:
+		out.printf("%s... %d unchanged keys ...\n", strings.Repeat(" ", depth*stylishIndent), hidden)
+	}
+}
+
+// writeRoot writes the entry comparing two roots that are not both objects
+func (f *FormatterStylish) writeRoot(out *errWriter, entry DiffEntry) {
+	var lines []string
+	line := func(marker string, value interface{}, pos parsing.Position) {
+		lines = append(lines, marker+" "+f.withPosition(stringifyStylish(value, 0), pos))
+	}
+	switch entry.Status {
+	case StatusAdded:
+		line("+", entry.NewVal, entry.NewPos)
+	case StatusRemoved:
+		line("-", entry.OldVal, entry.OldPos)
+	case StatusChanged:
+		line("-", entry.OldVal, entry.OldPos)
+		line("+", entry.NewVal, entry.NewPos)
+	default:
+		line(" ", entry.OldVal, entry.OldPos)
+	}
+	out.printf("%s", strings.Join(lines, "\n"))
+}
 
-	result.WriteString("}")
+// writeLine writes a single key with its marker and value
+func (f *FormatterStylish) writeLine(out *errWriter, depth int, marker, key string, value interface{}, pos parsing.Position) {
+	out.printf("%s%s %s: %s\n", markerIndent(depth), marker, key, f.withPosition(stringifyStylish(value, depth), pos))
+}
+
+// withPosition ends the first line of a rendered value with the position comment
+func (f *FormatterStylish) withPosition(rendered string, pos parsing.Position) string {
+	comment := f.positionComment(pos)
+	if comment == "" {
+		return rendered
+	}
+	first, rest, found := strings.Cut(rendered, "\n")
+	if !found {
+		return first + comment
+	}
+	return first + comment + "\n" + rest
+}
+
+// positionComment returns the comment ending the line of a key at pos
+func (f *FormatterStylish) positionComment(pos parsing.Position) string {
+	if !f.ShowPositions || !pos.IsValid() {
+		return ""
+	}
+	return "  # " + pos.String()
+}
+
+// markerIndent returns the indentation in front of the +/- marker at the given depth
+func markerIndent(depth int) string {
+	return strings.Repeat(" ", depth*stylishIndent-2)
+}
+
+// stringifyStylish renders a value, expanding maps into indented blocks
+func stringifyStylish(value interface{}, depth int) string {
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		return formatValue(value)
+	}
+
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var result strings.Builder
+	result.WriteString("{\n")
+	for _, k := range keys {
+		result.WriteString(fmt.Sprintf("%s%s: %s\n",
+			strings.Repeat(" ", (depth+1)*stylishIndent), k, stringifyStylish(m[k], depth+1)))
+	}
+	result.WriteString(strings.Repeat(" ", depth*stylishIndent) + "}")
 	return result.String()
 }
@@ -0,0 +1,425 @@
+package code
+
+import (
+	"bytes"
+	"code/helpers"
+	"code/parsing"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// keysFormatter is a minimal third-party style formatter used in tests
+type keysFormatter struct{}
+
+func (f *keysFormatter) Format(diff []DiffEntry) string {
+	keys := make([]string, 0, len(diff))
+	for _, entry := range diff {
+		keys = append(keys, entry.Key)
+	}
+	return strings.Join(keys, ",")
+}
+
+func TestRegisterFormatter(t *testing.T) {
+	RegisterFormatter("test-keys", func(FormatOptions) Formatter { return &keysFormatter{} })
+
+	assert.Contains(t, Formatters(), "test-keys")
+	assert.Contains(t, Formatters(), "stylish")
+
+	got, err := GenDiff(
+		helpers.CreateTempJSON(t, `{"a": 1, "b": 2}`),
+		helpers.CreateTempJSON(t, `{"b": 3, "c": 4}`),
+		"test-keys",
+	)
+	require.NoError(t, err)
+	assert.Equal(t, "a,b,c", got)
+}
+
+func TestRegisterFormatterPanics(t *testing.T) {
+	factory := func(FormatOptions) Formatter { return &keysFormatter{} }
+
+	tests := []struct {
+		name     string
+		register func()
+	}{
+		{name: "empty name", register: func() { RegisterFormatter("", factory) }},
+		{name: "nil factory", register: func() { RegisterFormatter("test-nil", nil) }},
+		{name: "duplicate name", register: func() { RegisterFormatter("stylish", factory) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Panics(t, tt.register)
+		})
+	}
+}
+
+func TestFormattersSorted(t *testing.T) {
+	names := Formatters()
+	for i := 1; i < len(names); i++ {
+		assert.Less(t, names[i-1], names[i], fmt.Sprintf("formatters not sorted: %v", names))
+	}
+}
+
+func TestNewFormatterUnsupported(t *testing.T) {
+	_, err := NewFormatter("does-not-exist")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unsupported format")
+}
+
+// failingWriter fails every write with errWrite
+type failingWriter struct{}
+
+var errWrite = errors.New("write failed")
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errWrite
+}
+
+func TestWriteDiff(t *testing.T) {
+	diff := []DiffEntry{
+		{Key: "a", Status: StatusUnchanged, OldVal: 1},
+		{Key: "b", Status: StatusChanged, OldVal: 2, NewVal: 3},
+	}
+
+	tests := []struct {
+		name      string
+		formatter Formatter
+		want      string
+	}{
+		{
+			name:      "streaming formatter",
+			formatter: &FormatterStylish{},
+			want:      "{\n    a: 1\n  - b: 2\n  + b: 3\n}",
+		},
+		{
+			name:      "string-only formatter",
+			formatter: &keysFormatter{},
+			want:      "a,b",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, WriteDiff(&buf, tt.formatter, diff))
+			assert.Equal(t, tt.want, buf.String())
+			assert.Equal(t, tt.want, tt.formatter.Format(diff))
+
+			err := WriteDiff(failingWriter{}, tt.formatter, diff)
+			assert.ErrorIs(t, err, errWrite)
+		})
+	}
+}
+
+func TestGenDiffTo(t *testing.T) {
+	file1 := helpers.CreateTempJSON(t, `{"key": "old"}`)
+	file2 := helpers.CreateTempJSON(t, `{"key": "new"}`)
+
+	var buf bytes.Buffer
+	require.NoError(t, GenDiffTo(&buf, file1, file2, Options{Format: "stylish"}))
+	assert.Equal(t, "{\n  - key: old\n  + key: new\n}", buf.String())
+
+	err := GenDiffTo(failingWriter{}, file1, file2, Options{Format: "stylish"})
+	assert.ErrorIs(t, err, errWrite)
+}
+
+func TestFormatterStylishContext(t *testing.T) {
+	data1 := map[string]interface{}{
+		"a": 1, "b": 2, "c": 3, "d": 4, "e": 5,
+		"nested": map[string]interface{}{"x": 1, "y": 2, "z": 3},
+	}
+	data2 := map[string]interface{}{
+		"a": 1, "b": 2, "c": 30, "d": 4, "e": 5,
+		"nested": map[string]interface{}{"x": 1, "y": 2, "z": 4},
+	}
+
+	tests := []struct {
+		name string
+		opts FormatOptions
+		want string
+	}{
+		{
+			name: "no context limit",
+			opts: FormatOptions{},
+			want: "{\n    a: 1\n    b: 2\n  - c: 3\n  + c: 30\n    d: 4\n    e: 5\n" +
+				"    nested: {\n        x: 1\n        y: 2\n      - z: 3\n      + z: 4\n    }\n}",
+		},
+		{
+			name: "zero context",
+			opts: FormatOptions{LimitContext: true},
+			want: "{\n    ... 2 unchanged keys ...\n  - c: 3\n  + c: 30\n    ... 2 unchanged keys ...\n" +
+				"    nested: {\n        ... 2 unchanged keys ...\n      - z: 3\n      + z: 4\n    }\n}",
+		},
+		{
+			name: "one line of context",
+			opts: FormatOptions{LimitContext: true, Context: 1},
+			want: "{\n    ... 1 unchanged key ...\n    b: 2\n  - c: 3\n  + c: 30\n    d: 4\n    e: 5\n" +
+				"    nested: {\n        ... 1 unchanged key ...\n        y: 2\n      - z: 3\n      + z: 4\n    }\n}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formatter, err := NewFormatterWithOptions("stylish", tt.opts)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, formatter.Format(computeDiff(data1, data2)))
+		})
+	}
+}
+
+func TestFormatterNames(t *testing.T) {
+	data1 := map[string]interface{}{
+		"db":      map[string]interface{}{"host": "a", "port": 5432, "pool": map[string]interface{}{"size": 1}},
+		"removed": true,
+		"same":    "x",
+	}
+	data2 := map[string]interface{}{
+		"added": 1,
+		"db":    map[string]interface{}{"host": "b", "port": "5432", "pool": 10},
+		"same":  "x",
+	}
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{
+			format: "name-only",
+			want:   "added\ndb.host\ndb.pool\ndb.port\nremoved",
+		},
+		{
+			format: "name-status",
+			want:   "A\tadded\nM\tdb.host\nT\tdb.pool\nT\tdb.port\nD\tremoved",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			formatter, err := NewFormatter(tt.format)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, formatter.Format(computeDiff(data1, data2)))
+		})
+	}
+}
+
+func TestFormatPositions(t *testing.T) {
+	pos := func(file string, line int) parsing.Position {
+		return parsing.Position{File: file, Line: line, Column: 3}
+	}
+	diff := []DiffEntry{
+		{Key: "added", Status: StatusAdded, NewVal: map[string]interface{}{"x": 1}, NewPos: pos("b.yaml", 2)},
+		{Key: "host", Status: StatusChanged, OldVal: "a", NewVal: "b", OldPos: pos("a.json", 4), NewPos: pos("b.yaml", 5)},
+		{Key: "ini", Status: StatusRemoved, OldVal: 1},
+	}
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{
+			format: "stylish",
+			want: "{\n  + added: {  # b.yaml:2:3\n        x: 1\n    }\n" +
+				"  - host: a  # a.json:4:3\n  + host: b  # b.yaml:5:3\n  - ini: 1\n}",
+		},
+		{
+			format: "name-status",
+			want:   "A\tadded\tb.yaml:2:3\nM\thost\ta.json:4:3\tb.yaml:5:3\nD\tini",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			formatter, err := NewFormatterWithOptions(tt.format, FormatOptions{ShowPositions: true})
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, formatter.Format(diff))
+		})
+	}
+}
+
+func TestFormatterDOT(t *testing.T) {
+	diff := computeDiff(
+		map[string]interface{}{"same": map[string]interface{}{"a": 1}, "name": `say "hi"`},
+		map[string]interface{}{"same": map[string]interface{}{"a": 1}, "name": "bye", "new": nil},
+	)
+
+	tests := []struct {
+		name string
+		opts FormatOptions
+		want string
+	}{
+		{
+			name: "full tree",
+			want: "digraph diff {\n  node [shape=box, style=filled];\n  n0 [label=\"/\", fillcolor=white];\n" +
+				"  n1 [label=\"name\\n- say \\\"hi\\\"\\n+ bye\", fillcolor=khaki];\n  n0 -> n1;\n" +
+				"  n2 [label=\"new: null\", fillcolor=palegreen];\n  n0 -> n2;\n" +
+				"  n3 [label=\"same\", fillcolor=white];\n  n0 -> n3;\n" +
+				"  n4 [label=\"a: 1\", fillcolor=white];\n  n3 -> n4;\n}",
+		},
+		{
+			name: "pruned unchanged subtrees",
+			opts: FormatOptions{PruneUnchanged: true},
+			want: "digraph diff {\n  node [shape=box, style=filled];\n  n0 [label=\"/\", fillcolor=white];\n" +
+				"  n1 [label=\"name\\n- say \\\"hi\\\"\\n+ bye\", fillcolor=khaki];\n  n0 -> n1;\n" +
+				"  n2 [label=\"new: null\", fillcolor=palegreen];\n  n0 -> n2;\n}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formatter, err := NewFormatterWithOptions("dot", tt.opts)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, formatter.Format(diff))
+		})
+	}
+}
+
+func TestFormatterSQL(t *testing.T) {
+	diff := computeDiff(
+		map[string]interface{}{
+			"db":      map[string]interface{}{"host": "old", "port": 5432},
+			"name":    "it's",
+			"removed": map[string]interface{}{"a": 1, "b": map[string]interface{}{"c": true}},
+		},
+		map[string]interface{}{
+			"db":    map[string]interface{}{"host": `new\host`, "port": 5432},
+			"name":  map[string]interface{}{"first": "x"},
+			"tags":  []interface{}{"a", "b"},
+			"empty": nil,
+		},
+	)
+
+	tests := []struct {
+		name    string
+		opts    SQLOptions
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+			want: "UPDATE \"config\" SET \"value\" = 'new\\host' WHERE \"key\" = 'db.host';\n" +
+				"INSERT INTO \"config\" (\"key\", \"value\") VALUES ('empty', NULL);\n" +
+				"DELETE FROM \"config\" WHERE \"key\" = 'name';\n" +
+				"INSERT INTO \"config\" (\"key\", \"value\") VALUES ('name.first', 'x');\n" +
+				"DELETE FROM \"config\" WHERE \"key\" = 'removed.a';\n" +
+				"DELETE FROM \"config\" WHERE \"key\" = 'removed.b.c';\n" +
+				"INSERT INTO \"config\" (\"key\", \"value\") VALUES ('tags', '[\"a\",\"b\"]');",
+		},
+		{
+			name: "mysql in a transaction",
+			opts: SQLOptions{Table: "settings", KeyColumn: "k", ValueColumn: "v", Dialect: "mysql", Transaction: true},
+			want: "START TRANSACTION;\n" +
+				"UPDATE `settings` SET `v` = 'new\\\\host' WHERE `k` = 'db.host';\n" +
+				"INSERT INTO `settings` (`k`, `v`) VALUES ('empty', NULL);\n" +
+				"DELETE FROM `settings` WHERE `k` = 'name';\n" +
+				"INSERT INTO `settings` (`k`, `v`) VALUES ('name.first', 'x');\n" +
+				"DELETE FROM `settings` WHERE `k` = 'removed.a';\n" +
+				"DELETE FROM `settings` WHERE `k` = 'removed.b.c';\n" +
+				"INSERT INTO `settings` (`k`, `v`) VALUES ('tags', '[\"a\",\"b\"]');\n" +
+				"COMMIT;",
+		},
+		{
+			name:    "unknown dialect",
+			opts:    SQLOptions{Dialect: "oracle"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formatter, err := NewFormatterWithOptions("sql", FormatOptions{SQL: tt.opts})
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			var buf bytes.Buffer
+			require.NoError(t, WriteDiff(&buf, formatter, diff))
+			assert.Equal(t, tt.want, buf.String())
+		})
+	}
+}
+
+func TestFormatterSQLNumbers(t *testing.T) {
+	diff := computeDiff(
+		map[string]interface{}{"timeout": float64(60), "ratio": 0.5},
+		map[string]interface{}{"timeout": float64(3600000), "ratio": 0.25},
+	)
+	formatter, err := NewFormatter("sql")
+	require.NoError(t, err)
+	assert.Equal(t, "UPDATE \"config\" SET \"value\" = '0.25' WHERE \"key\" = 'ratio';\n"+
+		"UPDATE \"config\" SET \"value\" = '3600000' WHERE \"key\" = 'timeout';", formatter.Format(diff))
+}
+
+func TestFormatterSQLTaggedValues(t *testing.T) {
+	diff := computeDiff(
+		map[string]interface{}{
+			"password": parsing.TaggedValue{Tag: "!secret", Value: "abc"},
+			"db":       parsing.TaggedValue{Tag: "!vault", Value: map[string]interface{}{"user": "a"}},
+		},
+		map[string]interface{}{
+			"password": parsing.TaggedValue{Tag: "!secret", Value: "abd"},
+			"db":       map[string]interface{}{"user": "b"},
+		},
+	)
+	formatter, err := NewFormatter("sql")
+	require.NoError(t, err)
+	assert.Equal(t, "DELETE FROM \"config\" WHERE \"key\" = 'db.user';\n"+
+		"INSERT INTO \"config\" (\"key\", \"value\") VALUES ('db.user', 'b');\n"+
+		"UPDATE \"config\" SET \"value\" = 'abd' WHERE \"key\" = 'password';", formatter.Format(diff))
+}
+
+func TestFormatterSQLRoot(t *testing.T) {
+	formatter, err := NewFormatter("sql")
+	require.NoError(t, err)
+	diff := []DiffEntry{{Root: true, Status: StatusChanged, OldVal: []interface{}{"a"}, NewVal: []interface{}{"b"}}}
+	var got strings.Builder
+	require.EqualError(t, formatter.(StreamFormatter).FormatTo(&got, diff), "sql format needs both files to be objects")
+	assert.Empty(t, got.String())
+	assert.Equal(t, "-- error: sql format needs both files to be objects", formatter.Format(diff))
+	assert.Equal(t, "-- error: unsupported sql dialect: oracle",
+		(&FormatterSQL{Options: SQLOptions{Dialect: "oracle"}}).Format(diff))
+}
+
+func TestFormatterJQ(t *testing.T) {
+	tests := []struct {
+		name  string
+		data1 map[string]interface{}
+		data2 map[string]interface{}
+		want  string
+	}{
+		{
+			name:  "no changes",
+			data1: map[string]interface{}{"a": 1},
+			data2: map[string]interface{}{"a": 1},
+			want:  ".",
+		},
+		{
+			name: "set and delete paths",
+			data1: map[string]interface{}{
+				"db":  map[string]interface{}{"host": "old", "port": 5432},
+				"old": true,
+			},
+			data2: map[string]interface{}{
+				"db":  map[string]interface{}{"host": "<new>", "port": 5432},
+				"new": map[string]interface{}{"list": []interface{}{1, "two"}},
+			},
+			want: "setpath([\"db\",\"host\"]; \"<new>\")\n" +
+				"| setpath([\"new\"]; {\"list\":[1,\"two\"]})\n" +
+				"| delpaths([[\"old\"]])",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formatter, err := NewFormatter("jq")
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, formatter.Format(computeDiff(tt.data1, tt.data2)))
+		})
+	}
+}
@@ -4,32 +4,125 @@ package code
 
 import (
 	"code/parsing"
+	"fmt"
+	"io"
+	"reflect"
 	"sort"
+	"strings"
 )
 
+// Options controls how GenDiffTo reads its inputs and renders the differences
+type Options struct {
+	Format        string
+	FormatOptions FormatOptions
+	ParseOptions  parsing.Options
+	// InputFormat1 and InputFormat2 override ParseOptions.Format for one file
+	InputFormat1 string
+	InputFormat2 string
+}
+
 // GenDiff compares two configuration files and returns a string representation
 // of the differences. The format parameter controls the output format.
 func GenDiff(filepath1, filepath2, format string) (string, error) {
-	data1, err := parsing.ParseFile(filepath1)
-	if err != nil {
-		return "", err
-	}
-	data2, err := parsing.ParseFile(filepath2)
-	if err != nil {
+	var result strings.Builder
+	if err := GenDiffTo(&result, filepath1, filepath2, Options{Format: format}); err != nil {
 		return "", err
 	}
+	return result.String(), nil
+}
 
-	// Compute the differences
-	diff := computeDiff(data1, data2)
+// GenDiffTo compares two configuration files and streams the formatted
+// differences to w. Write errors are returned to the caller.
+func GenDiffTo(w io.Writer, filepath1, filepath2 string, opts Options) error {
+	if filepath1 == parsing.StdinPath && filepath2 == parsing.StdinPath {
+		return fmt.Errorf("only one of the files can be read from stdin")
+	}
 
 	// Get the appropriate formatter
-	formatter, err := NewFormatter(format)
+	formatter, err := NewFormatterWithOptions(opts.Format, opts.FormatOptions)
 	if err != nil {
-		return "", err
+		return err
+	}
+
+	// Each file hints at the format of the other one when it has to be
+	// recognised by its content
+	format1 := opts.inputFormat(filepath1, opts.InputFormat1)
+	format2 := opts.inputFormat(filepath2, opts.InputFormat2)
+	doc1, err := parsing.ParseDocument(filepath1, opts.parseOptions(opts.InputFormat1, format2))
+	if err != nil {
+		return err
+	}
+	doc2, err := parsing.ParseDocument(filepath2, opts.parseOptions(opts.InputFormat2, format1))
+	if err != nil {
+		return err
+	}
+
+	// Pair the documents of a YAML stream with a single document by identity
+	if doc1.Keyed != doc2.Keyed {
+		doc1.KeyByIdentity(opts.ParseOptions.Identity)
+		doc2.KeyByIdentity(opts.ParseOptions.Identity)
+	}
+
+	// Compute the differences and locate them in both files
+	diff := diffDocuments(doc1.Data, doc2.Data)
+	locateDiff(diff, nil, doc1, doc2)
+
+	// Format straight into the writer
+	return WriteDiff(w, formatter, diff)
+}
+
+// parseOptions returns the parse options of one file, with its format
+// override applied and the format of the other file as a hint
+func (o Options) parseOptions(inputFormat, hint string) parsing.Options {
+	parseOpts := o.ParseOptions
+	if inputFormat != "" {
+		parseOpts.Format = inputFormat
+	}
+	if parseOpts.FormatHint == "" {
+		parseOpts.FormatHint = hint
+	}
+	return parseOpts
+}
+
+// inputFormat returns the format of a file when it is forced or given by
+// its name, and an empty string when it has to be recognised by its content
+func (o Options) inputFormat(filepath, inputFormat string) string {
+	switch {
+	case inputFormat != "":
+		return inputFormat
+	case o.ParseOptions.Format != "":
+		return o.ParseOptions.Format
+	}
+	return parsing.FormatFromPath(filepath)
+}
+
+// diffDocuments compares the roots of two documents. Objects are compared
+// key by key, any other roots give a single Root entry. An empty document
+// stands for an empty object next to an object.
+func diffDocuments(root1, root2 interface{}) []DiffEntry {
+	map1, isMap1 := root1.(map[string]interface{})
+	map2, isMap2 := root2.(map[string]interface{})
+	if (isMap1 || root1 == nil) && (isMap2 || root2 == nil) {
+		return computeDiff(map1, map2)
 	}
 
-	// Format and return the result
-	return formatter.Format(diff), nil
+	entry := DiffEntry{Root: true}
+	switch {
+	case root1 == nil:
+		entry.Status = StatusAdded
+		entry.NewVal = root2
+	case root2 == nil:
+		entry.Status = StatusRemoved
+		entry.OldVal = root1
+	case !reflect.DeepEqual(root1, root2):
+		entry.Status = StatusChanged
+		entry.OldVal = root1
+		entry.NewVal = root2
+	default:
+		entry.Status = StatusUnchanged
+		entry.OldVal = root1
+	}
+	return []DiffEntry{entry}
 }
 
 // computeDiff calculates the differences between two data maps
@@ -66,7 +159,10 @@ func computeDiff(data1, data2 map[string]interface{}) []DiffEntry {
 		case !exists2:
 			entry.Status = StatusRemoved
 			entry.OldVal = val1
-		case val1 != val2:
+		case isMap(val1) && isMap(val2):
+			entry.Status = StatusNested
+			entry.Children = computeDiff(val1.(map[string]interface{}), val2.(map[string]interface{}))
+		case !reflect.DeepEqual(val1, val2):
 			entry.Status = StatusChanged
 			entry.OldVal = val1
 			entry.NewVal = val2
@@ -80,3 +176,24 @@ func computeDiff(data1, data2 map[string]interface{}) []DiffEntry {
 
 	return diff
 }
+
+// locateDiff sets the positions of the entries found in each document
+func locateDiff(diff []DiffEntry, path []string, doc1, doc2 *parsing.Document) {
+	for i := range diff {
+		entry := &diff[i]
+		entryPath := entry.path(path)
+		if entry.Status != StatusAdded {
+			entry.OldPos = doc1.Position(entryPath)
+		}
+		if entry.Status != StatusRemoved {
+			entry.NewPos = doc2.Position(entryPath)
+		}
+		locateDiff(entry.Children, entryPath, doc1, doc2)
+	}
+}
+
+// isMap reports whether the value is a nested map that can be diffed key by key
+func isMap(value interface{}) bool {
+	_, ok := value.(map[string]interface{})
+	return ok
+}
@@ -2,6 +2,10 @@ package code
 
 import (
 	"code/helpers"
+	"code/parsing"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -52,6 +56,14 @@ func TestGenDiffJSON(t *testing.T) {
 			format: "stylish",
 			want:   "{\n    a: 1\n  - b: 2\n  + b: 20\n  - c: 3\n  + d: 4\n}",
 		},
+		{
+			name:   "nested objects",
+			file1:  helpers.CreateTempJSON(t, `{"common": {"a": 1, "b": {"c": true}}, "gone": {"x": 1}}`),
+			file2:  helpers.CreateTempJSON(t, `{"common": {"a": 2, "b": {"c": true}}, "list": [1, 2]}`),
+			format: "stylish",
+			want: "{\n    common: {\n      - a: 1\n      + a: 2\n        b: {\n            c: true\n        }\n    }\n" +
+				"  - gone: {\n        x: 1\n    }\n  + list: [1 2]\n}",
+		},
 		{
 			name:    "file1 does not exist",
 			file1:   "nonexistent.json",
@@ -66,6 +78,13 @@ func TestGenDiffJSON(t *testing.T) {
 			format:  "stylish",
 			wantErr: true,
 		},
+		{
+			name:    "both files from stdin",
+			file1:   "-",
+			file2:   "-",
+			format:  "stylish",
+			wantErr: true,
+		},
 		{
 			name:    "unsupported format",
 			file1:   helpers.CreateTempJSON(t, `{}`),
@@ -167,6 +186,14 @@ func TestGenDiffYAML(t *testing.T) {
 			format:    "stylish",
 			want:      "{\n    host: localhost\n  - port: 8080\n  + port: 443\n  - ssl: false\n  + ssl: true\n  + timeout: 30\n}",
 		},
+		{
+			name:      "yaml merge keys and tags",
+			filepath1: helpers.CreateTempYAML(t, "base: &b {port: 80}\nweb:\n  <<: *b\n  password: !secret old"),
+			filepath2: helpers.CreateTempYAML(t, "base: &b {port: 80}\nweb:\n  <<: *b\n  port: 8080\n  password: !vault old"),
+			format:    "stylish",
+			want: "{\n    base: {\n        port: 80\n    }\n    web: {\n      - password: !secret old\n" +
+				"      + password: !vault old\n      - port: 80\n      + port: 8080\n    }\n}",
+		},
 		{
 			name:      "mixed json and yaml files",
 			filepath1: helpers.CreateTempJSON(t, `{"key": "value1"}`),
@@ -196,6 +223,44 @@ func TestGenDiffYAML(t *testing.T) {
 	}
 }
 
+func TestGenDiffMultiDocumentYAML(t *testing.T) {
+	file1 := helpers.CreateTempYAML(t, "kind: Service\nmetadata:\n  name: web\n---\n"+
+		"kind: Deployment\nmetadata:\n  name: web\nreplicas: 1\n")
+	file2 := helpers.CreateTempYAML(t, "kind: Deployment\nmetadata:\n  name: web\nreplicas: 2\n---\n"+
+		"kind: ConfigMap\nmetadata:\n  name: web\n")
+
+	var got strings.Builder
+	err := GenDiffTo(&got, file1, file2, Options{
+		Format:       "name-status",
+		ParseOptions: parsing.Options{MultiDocument: true},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "A\tConfigMap/web\nM\tDeployment/web.replicas\nD\tService/web", got.String())
+}
+
+func TestGenDiffMultiDocumentAgainstSingleDocument(t *testing.T) {
+	stream := helpers.CreateTempYAML(t, "kind: Service\nmetadata:\n  name: web\n---\n"+
+		"kind: Deployment\nmetadata:\n  name: web\nreplicas: 1\n")
+	single := helpers.CreateTempYAML(t, "kind: Deployment\nmetadata:\n  name: web\nreplicas: 2\n")
+
+	tests := []struct {
+		name         string
+		file1, file2 string
+		want         string
+	}{
+		{name: "stream first", file1: stream, file2: single, want: "M\tDeployment/web.replicas\nD\tService/web"},
+		{name: "stream second", file1: single, file2: stream, want: "M\tDeployment/web.replicas\nA\tService/web"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GenDiff(tt.file1, tt.file2, "name-status")
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
 func TestComputeDiff(t *testing.T) {
 	tests := []struct {
 		name  string
@@ -276,6 +341,24 @@ func TestComputeDiff(t *testing.T) {
 				{Key: "flag", Status: StatusChanged, OldVal: true, NewVal: false},
 			},
 		},
+		{
+			name:  "equal arrays",
+			data1: map[string]interface{}{"list": []interface{}{1, 2}},
+			data2: map[string]interface{}{"list": []interface{}{1, 2}},
+			want: []DiffEntry{
+				{Key: "list", Status: StatusUnchanged, OldVal: []interface{}{1, 2}},
+			},
+		},
+		{
+			name:  "nested maps",
+			data1: map[string]interface{}{"outer": map[string]interface{}{"a": 1}},
+			data2: map[string]interface{}{"outer": map[string]interface{}{"a": 2}},
+			want: []DiffEntry{
+				{Key: "outer", Status: StatusNested, Children: []DiffEntry{
+					{Key: "a", Status: StatusChanged, OldVal: 1, NewVal: 2},
+				}},
+			},
+		},
 		{
 			name:  "numeric values",
 			data1: map[string]interface{}{"count": float64(10)},
@@ -295,7 +378,143 @@ func TestComputeDiff(t *testing.T) {
 				assert.Equal(t, tt.want[i].Status, entry.Status)
 				assert.Equal(t, tt.want[i].OldVal, entry.OldVal)
 				assert.Equal(t, tt.want[i].NewVal, entry.NewVal)
+				assert.Equal(t, tt.want[i].Children, entry.Children)
 			}
 		})
 	}
 }
+
+func TestGenDiffPositions(t *testing.T) {
+	file1 := helpers.CreateTempYAML(t, "db:\n  host: a\n  port: 1\n")
+	file2 := helpers.CreateTempYAML(t, "db:\n  port: 1\n  host: b\n")
+
+	var got strings.Builder
+	err := GenDiffTo(&got, file1, file2, Options{
+		Format:        "name-status",
+		FormatOptions: FormatOptions{ShowPositions: true},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "M\tdb.host\t"+file1+":2:3\t"+file2+":3:3", got.String())
+}
+
+func TestGenDiffNonObjectRoots(t *testing.T) {
+	tests := []struct {
+		name   string
+		file1  string
+		file2  string
+		format string
+		want   string
+	}{
+		{
+			name:   "arrays",
+			file1:  `["a", "b"]`,
+			file2:  `["a", "c"]`,
+			format: "stylish",
+			want:   "- [a b]\n+ [a c]",
+		},
+		{
+			name:   "equal scalars",
+			file1:  `true`,
+			file2:  `true`,
+			format: "stylish",
+			want:   "  true",
+		},
+		{
+			name:   "scalar to object",
+			file1:  `1`,
+			file2:  `{"a": 1}`,
+			format: "stylish",
+			want:   "- 1\n+ {\n    a: 1\n}",
+		},
+		{
+			name:   "name-status",
+			file1:  `[1]`,
+			file2:  `{}`,
+			format: "name-status",
+			want:   "T\t.",
+		},
+		{
+			name:   "jq",
+			file1:  `[1]`,
+			file2:  `[2]`,
+			format: "jq",
+			want:   "setpath([]; [2])",
+		},
+		{
+			name:   "dot",
+			file1:  `["x", "y", "z"]`,
+			file2:  `["x", "y", "z"]`,
+			format: "dot",
+			want: "digraph diff {\n  node [shape=box, style=filled];\n" +
+				"  n0 [label=\"/\", fillcolor=white];\n" +
+				"  n1 [label=\"[x y z]\", fillcolor=white];\n  n0 -> n1;\n}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GenDiff(helpers.CreateTempJSON(t, tt.file1), helpers.CreateTempJSON(t, tt.file2), tt.format)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestGenDiffResolveRefs(t *testing.T) {
+	db1 := helpers.CreateTempYAML(t, "host: a\nport: 5432\n")
+	db2 := helpers.CreateTempYAML(t, "host: b\nport: 5432\n")
+	file1 := helpers.CreateTempYAML(t, "db: !include "+filepath.Base(db1)+"\n")
+	file2 := helpers.CreateTempYAML(t, "db:\n  $ref: "+filepath.Base(db2)+"\n")
+
+	var got strings.Builder
+	err := GenDiffTo(&got, file1, file2, Options{
+		Format:       "name-status",
+		ParseOptions: parsing.Options{ResolveRefs: true},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "M\tdb.host", got.String())
+}
+
+func TestGenDiffNumbersAcrossFormats(t *testing.T) {
+	file1 := helpers.CreateTempFile(t, "*.toml", "port = 8080\ntimeout = 3600000\n")
+	file2 := helpers.CreateTempJSON(t, `{"port": 8080, "timeout": 3600001}`)
+
+	got, err := GenDiff(file1, file2, "stylish")
+	require.NoError(t, err)
+	assert.Equal(t, "{\n    port: 8080\n  - timeout: 3600000\n  + timeout: 3600001\n}", got)
+}
+
+func TestGenDiffExpandEnvTemplate(t *testing.T) {
+	template := helpers.CreateTempYAML(t, "host: ${HOST:-localhost}\nport: ${PORT:-8080}\ndebug: ${DEBUG:-false}\n")
+	rendered := helpers.CreateTempYAML(t, "host: localhost\nport: 8080\ndebug: false\n")
+	opts := Options{
+		Format:       "name-status",
+		ParseOptions: parsing.Options{ExpandEnv: true, Env: map[string]string{}},
+	}
+
+	var got strings.Builder
+	require.NoError(t, GenDiffTo(&got, template, rendered, opts))
+	assert.Empty(t, got.String())
+}
+
+func TestGenDiffLargeIntegers(t *testing.T) {
+	file1 := helpers.CreateTempFile(t, "*.toml", "big = 9007199254740993\n")
+	file2 := helpers.CreateTempYAML(t, "big: 9007199254740992\n")
+
+	got, err := GenDiff(file1, file2, "stylish")
+	require.NoError(t, err)
+	assert.Equal(t, "{\n  - big: 9007199254740993\n  + big: 9007199254740992\n}", got)
+}
+
+func TestGenDiffSniffsWithTheOtherFormat(t *testing.T) {
+	dir := t.TempDir()
+	content := "[server]\nhost = \"a\"\nport = 80\n"
+	file1 := filepath.Join(dir, "app.conf")
+	file2 := filepath.Join(dir, "app.conf.bak")
+	require.NoError(t, os.WriteFile(file1, []byte(content), 0o600))
+	require.NoError(t, os.WriteFile(file2, []byte(content), 0o600))
+
+	var got strings.Builder
+	require.NoError(t, GenDiffTo(&got, file1, file2, Options{Format: "name-status"}))
+	assert.Empty(t, got.String())
+}
@@ -0,0 +1,169 @@
+package parsing
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// ArchiveSeparator separates the path of an archive from the path of a file
+// inside it, as in release.tar.gz!config/app.yaml
+const ArchiveSeparator = "!"
+
+// archiveKind returns "tar" or "zip" when the file name is the one of an
+// archive, compressed tarballs included, and an empty string otherwise
+func archiveKind(filepath string) string {
+	name := strings.ToLower(filepath)
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return "zip"
+	case strings.HasSuffix(trimCompressionExt(name), ".tar"),
+		strings.HasSuffix(name, ".tgz"), strings.HasSuffix(name, ".tbz2"):
+		return "tar"
+	default:
+		return ""
+	}
+}
+
+// splitArchivePath splits a reference to a file inside an archive into the
+// path of the archive and the path of the member. Existing files whose name
+// contains the separator are not references.
+func splitArchivePath(filepath string) (archive, member string, ok bool) {
+	if !strings.Contains(filepath, ArchiveSeparator) {
+		return "", "", false
+	}
+	if _, err := os.Stat(filepath); err == nil {
+		return "", "", false
+	}
+	for i := range filepath {
+		if strings.HasPrefix(filepath[i:], ArchiveSeparator) && archiveKind(filepath[:i]) != "" {
+			return filepath[:i], memberName(filepath[i+len(ArchiveSeparator):]), true
+		}
+	}
+	return "", "", false
+}
+
+// memberName normalises the path of an archive member, so that ./config/a.yaml
+// and config/a.yaml name the same member
+func memberName(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+// walkArchive calls fn with the name and content of every regular file of
+// a tar or zip archive. Compressed tarballs are decompressed as they are read.
+func walkArchive(archive string, fn func(name string, r io.Reader) error) error {
+	if archiveKind(archive) == "zip" {
+		return walkZip(archive, fn)
+	}
+	return walkTar(archive, fn)
+}
+
+func walkZip(archive string, fn func(name string, r io.Reader) error) error {
+	reader, err := zip.OpenReader(archive)
+	if err != nil {
+		return fmt.Errorf("failed to read archive: %w", err)
+	}
+	defer reader.Close()
+
+	for _, file := range reader.File {
+		if !file.Mode().IsRegular() {
+			continue
+		}
+		content, err := file.Open()
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %s: %w", file.Name, err)
+		}
+		err = fn(memberName(file.Name), content)
+		content.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func walkTar(archive string, fn func(name string, r io.Reader) error) error {
+	file, err := os.Open(archive)
+	if err != nil {
+		return fmt.Errorf("failed to read archive: %w", err)
+	}
+	defer file.Close()
+
+	decompressed, err := decompress(file, archive)
+	if err != nil {
+		return fmt.Errorf("failed to decompress archive: %w", err)
+	}
+	reader := tar.NewReader(decompressed)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %w", err)
+		}
+		if !header.FileInfo().Mode().IsRegular() {
+			continue
+		}
+		if err := fn(memberName(header.Name), reader); err != nil {
+			return err
+		}
+	}
+}
+
+// readArchiveMember reads and decompresses one file of an archive
+func readArchiveMember(archive, member string) ([]byte, error) {
+	var data []byte
+	found := false
+	err := walkArchive(archive, func(name string, r io.Reader) error {
+		if name != member {
+			return nil
+		}
+		content, err := readDecompressed(r, member)
+		if err != nil {
+			return fmt.Errorf("failed to read file: %s: %w", member, err)
+		}
+		data, found = content, true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("failed to read file: %s not found in %s", member, archive)
+	}
+	return data, nil
+}
+
+// parseArchive parses every file of an archive whose format is known into
+// a document mapping the path of each file to its content, so that two
+// archives are compared like two directories. Files in an unknown format,
+// such as READMEs or binaries, are left out.
+func parseArchive(archive string, opts Options) (*Document, error) {
+	data := map[string]interface{}{}
+	doc := newDocument(data)
+	err := walkArchive(archive, func(name string, r io.Reader) error {
+		if opts.Format == "" && FormatFromPath(name) == "" {
+			return nil
+		}
+		content, err := readDecompressed(r, name)
+		if err != nil {
+			return fmt.Errorf("failed to read file: %s: %w", name, err)
+		}
+		member, err := parseDocument(content, archive+ArchiveSeparator+name, opts)
+		if err != nil {
+			return err
+		}
+		data[name] = member.Data
+		member.nest(name, doc)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
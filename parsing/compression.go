@@ -0,0 +1,85 @@
+package parsing
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"path"
+	"strings"
+)
+
+// decompressor wraps a reader of compressed data into a reader of its content
+type decompressor func(r io.Reader) (io.Reader, error)
+
+// decompressors maps compression names to their decompressors
+var decompressors = map[string]decompressor{
+	"gzip": func(r io.Reader) (io.Reader, error) {
+		return gzip.NewReader(r)
+	},
+	"bzip2": func(r io.Reader) (io.Reader, error) {
+		return bzip2.NewReader(r), nil
+	},
+	"zlib": func(r io.Reader) (io.Reader, error) {
+		return zlib.NewReader(r)
+	},
+}
+
+// compressionExtensions maps the extensions of compressed files to their compression
+var compressionExtensions = map[string]string{
+	".gz":   "gzip",
+	".bz2":  "bzip2",
+	".zz":   "zlib",
+	".zlib": "zlib",
+}
+
+// zlibHeaders lists the first two bytes of zlib streams written with the
+// default window size at the fastest, default and best compression levels.
+// The header of the other levels is "x^" and is left out, a file starting
+// with it being more likely to be text.
+var zlibHeaders = [][]byte{{0x78, 0x01}, {0x78, 0x9c}, {0x78, 0xda}}
+
+// sniffCompression recognises a compression by the magic bytes of the data
+func sniffCompression(header []byte) string {
+	switch {
+	case bytes.HasPrefix(header, []byte{0x1f, 0x8b}):
+		return "gzip"
+	case len(header) >= 4 && bytes.HasPrefix(header, []byte("BZh")) && header[3] >= '1' && header[3] <= '9':
+		return "bzip2"
+	}
+	for _, magic := range zlibHeaders {
+		if bytes.HasPrefix(header, magic) {
+			return "zlib"
+		}
+	}
+	return ""
+}
+
+// decompress returns a reader of the decompressed content of r when the
+// extension of filepath or, failing that, its magic bytes tell it is
+// compressed, and a reader of r itself otherwise. Data is decompressed as
+// it is read.
+func decompress(r io.Reader, filepath string) (io.Reader, error) {
+	buffered := bufio.NewReader(r)
+	compression := compressionExtensions[strings.ToLower(path.Ext(filepath))]
+	if compression == "" {
+		header, _ := buffered.Peek(4)
+		compression = sniffCompression(header)
+	}
+	if compression == "" {
+		return buffered, nil
+	}
+	return decompressors[compression](buffered)
+}
+
+// trimCompressionExt removes the extension of a compressed file, so that
+// config.yaml.gz is parsed as config.yaml
+func trimCompressionExt(filepath string) string {
+	ext := path.Ext(filepath)
+	if _, ok := compressionExtensions[strings.ToLower(ext)]; ok {
+		return strings.TrimSuffix(filepath, ext)
+	}
+	return filepath
+}
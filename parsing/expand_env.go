@@ -0,0 +1,198 @@
+package parsing
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// expandEnv replaces the ${VAR} placeholders in the string values of a
+// parsed document with the values of the variables, looked up in env or,
+// when env is nil, in the process environment. Keys are left as they are,
+// so a variable can neither change the structure of the document nor break
+// its syntax. Strings written as plain YAML scalars, whose pointers are in
+// plain, get their type resolved again once expanded, so that port: ${PORT}
+// gives a number; other expanded values stay strings. The value is copied
+// rather than changed, YAML aliases sharing the maps of their anchor.
+//
+// ${VAR:-default} and ${VAR-default} use the default when VAR is unset or
+// empty, respectively unset; ${VAR:?message} and ${VAR?message} fail with
+// the message instead. Defaults and messages may hold placeholders too,
+// and $$ stands for a literal $.
+func expandEnv(value interface{}, plain map[string]bool, env map[string]string) (interface{}, error) {
+	lookup := os.LookupEnv
+	if env != nil {
+		lookup = func(name string) (string, bool) {
+			value, ok := env[name]
+			return value, ok
+		}
+	}
+	e := &envExpander{plain: plain, lookup: lookup}
+	return e.expand(value, nil)
+}
+
+// envExpander expands the placeholders of a document
+type envExpander struct {
+	plain  map[string]bool
+	lookup func(string) (string, bool)
+}
+
+// expand returns a copy of value, found at path in the document, with the
+// strings inside it expanded
+func (e *envExpander) expand(value interface{}, path []string) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		expanded, err := expandPlaceholders(v, e.lookup)
+		if err != nil {
+			if len(path) > 0 {
+				return nil, fmt.Errorf("%s: %w", strings.Join(path, "."), err)
+			}
+			return nil, err
+		}
+		if expanded != v && e.plain[Pointer(path)] {
+			return resolvePlainScalar(expanded)
+		}
+		return expanded, nil
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, child := range v {
+			expanded, err := e.expand(child, append(path[:len(path):len(path)], key))
+			if err != nil {
+				return nil, err
+			}
+			result[key] = expanded
+		}
+		return result, nil
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, child := range v {
+			expanded, err := e.expand(child, append(path[:len(path):len(path)], strconv.Itoa(i)))
+			if err != nil {
+				return nil, err
+			}
+			result[i] = expanded
+		}
+		return result, nil
+	case TaggedValue:
+		expanded, err := e.expand(v.Value, path)
+		if err != nil {
+			return nil, err
+		}
+		return TaggedValue{Tag: v.Tag, Value: expanded}, nil
+	}
+	return value, nil
+}
+
+// expandPlaceholders expands every placeholder of s
+func expandPlaceholders(s string, lookup func(string) (string, bool)) (string, error) {
+	var out strings.Builder
+	for i := 0; i < len(s); {
+		switch {
+		case strings.HasPrefix(s[i:], "$$"):
+			out.WriteByte('$')
+			i += 2
+		case strings.HasPrefix(s[i:], "${"):
+			end := closingBrace(s, i+2)
+			if end < 0 {
+				return "", fmt.Errorf("unterminated placeholder %q", s[i:])
+			}
+			value, err := expandVariable(s[i+2:end], lookup)
+			if err != nil {
+				return "", err
+			}
+			out.WriteString(value)
+			i = end + 1
+		default:
+			out.WriteByte(s[i])
+			i++
+		}
+	}
+	return out.String(), nil
+}
+
+// closingBrace returns the index of the brace closing the placeholder whose
+// content starts at from, skipping nested placeholders, or -1
+func closingBrace(s string, from int) int {
+	depth := 0
+	for i := from; i < len(s); i++ {
+		switch {
+		case strings.HasPrefix(s[i:], "${"):
+			depth++
+			i++
+		case s[i] == '}' && depth == 0:
+			return i
+		case s[i] == '}':
+			depth--
+		}
+	}
+	return -1
+}
+
+// expandVariable resolves the content of one placeholder, such as VAR:-default
+func expandVariable(expr string, lookup func(string) (string, bool)) (string, error) {
+	name := expr[:variableNameLength(expr)]
+	if name == "" {
+		return "", fmt.Errorf("invalid placeholder ${%s}", expr)
+	}
+	value, set := lookup(name)
+	operator, word := expr[len(name):], ""
+	for _, op := range []string{":-", "-", ":?", "?"} {
+		if strings.HasPrefix(operator, op) {
+			operator, word = op, operator[len(op):]
+			break
+		}
+	}
+
+	switch operator {
+	case "":
+		return value, nil
+	case ":-", "-":
+		if set && (operator == "-" || value != "") {
+			return value, nil
+		}
+		return expandPlaceholders(word, lookup)
+	case ":?", "?":
+		if set && (operator == "?" || value != "") {
+			return value, nil
+		}
+		message, err := expandPlaceholders(word, lookup)
+		if err != nil {
+			return "", err
+		}
+		if message == "" {
+			message = "is not set"
+		}
+		return "", fmt.Errorf("%s: %s", name, message)
+	default:
+		return "", fmt.Errorf("invalid placeholder ${%s}", expr)
+	}
+}
+
+// variableNameLength returns the length of the variable name expr starts with
+func variableNameLength(expr string) int {
+	for i, c := range expr {
+		letter := c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+		if !letter && (i == 0 || c < '0' || c > '9') {
+			return i
+		}
+	}
+	return len(expr)
+}
+
+// LoadEnvFile reads the variables of a dotenv file for Options.Env
+func LoadEnvFile(filepath string) (map[string]string, error) {
+	data, err := readInput(filepath, Options{})
+	if err != nil {
+		return nil, err
+	}
+	values, err := parseDotenv(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath, err)
+	}
+	env := make(map[string]string, len(values))
+	for name, value := range values {
+		env[name] = fmt.Sprintf("%v", value)
+	}
+	return env, nil
+}
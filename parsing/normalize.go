@@ -0,0 +1,106 @@
+package parsing
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Location names used by the TOML decoder for values without a time zone
+const (
+	tomlLocalDatetime = "datetime-local"
+	tomlLocalDate     = "date-local"
+	tomlLocalTime     = "time-local"
+)
+
+// normalize converts decoder specific types into the plain values produced by
+// encoding/json, so that documents in different formats compare equal:
+// slices of maps become []interface{}, integers become float64 and
+// timestamps become strings. Integers a float64 cannot hold exactly stay
+// int64, or uint64 above its range, so that they are not rounded.
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k, item := range v {
+			v[k] = normalize(item)
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = normalize(item)
+		}
+		return v
+	case []map[string]interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = normalize(item)
+		}
+		return items
+	case int:
+		return normalizeInt(int64(v))
+	case int64:
+		return normalizeInt(v)
+	case uint64:
+		return normalizeUint(v)
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return normalizeInt(i)
+		}
+		if u, err := strconv.ParseUint(v.String(), 10, 64); err == nil {
+			return normalizeUint(u)
+		}
+		f, _ := v.Float64()
+		return f
+	case time.Time:
+		return normalizeTime(v)
+	default:
+		return v
+	}
+}
+
+// normalizeInt returns i as a float64 when the conversion is exact
+func normalizeInt(i int64) interface{} {
+	if f := float64(i); f < math.MaxInt64 && int64(f) == i {
+		return f
+	}
+	return i
+}
+
+// normalizeUint returns u as a float64 when the conversion is exact
+func normalizeUint(u uint64) interface{} {
+	if f := float64(u); f < math.MaxUint64 && uint64(f) == u {
+		return f
+	}
+	return u
+}
+
+// FormatNumber writes a number the way it is usually written in a
+// configuration file: whole numbers without exponent, such as 3600000
+// rather than 3.6e+06, and other numbers in their shortest form.
+func FormatNumber(f float64) string {
+	if f == math.Trunc(f) && math.Abs(f) < 1e21 {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// normalizeTime formats a timestamp the way it is usually written in JSON.
+// Local TOML values keep their partial form and midnight UTC is written as
+// a plain date, which is how YAML resolves date-only timestamps.
+func normalizeTime(t time.Time) string {
+	switch t.Location().String() {
+	case tomlLocalDate:
+		return t.Format(time.DateOnly)
+	case tomlLocalTime:
+		return t.Format("15:04:05.999999999")
+	case tomlLocalDatetime:
+		return t.Format("2006-01-02T15:04:05.999999999")
+	}
+
+	midnight := t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 && t.Nanosecond() == 0
+	if t.Location() == time.UTC && midnight {
+		return t.Format(time.DateOnly)
+	}
+	return t.Format(time.RFC3339Nano)
+}
@@ -0,0 +1,188 @@
+package parsing
+
+import (
+	"fmt"
+	"strings"
+)
+
+// isDotenvName reports whether a file name follows the .env or .env.* convention
+func isDotenvName(name string) bool {
+	return name == ".env" || strings.HasPrefix(name, ".env.")
+}
+
+// parseDotenv parses KEY=VALUE lines. Keys may carry an "export" prefix.
+// Unquoted values end at the first " #", single-quoted values are literal
+// and double-quoted values may span lines and contain \n, \r, \t, \", \\
+// and \$ escapes. Later assignments of a key win.
+func parseDotenv(envData []byte) (map[string]interface{}, error) {
+	p := &dotenvParser{src: strings.ReplaceAll(string(envData), "\r\n", "\n"), line: 1}
+	result := map[string]interface{}{}
+
+	for {
+		p.skipBlankAndComments()
+		if p.eof() {
+			return result, nil
+		}
+		key, value, err := p.assignment()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse dotenv: line %d: %w", p.line, err)
+		}
+		result[key] = value
+	}
+}
+
+// dotenvParser walks the source of a dotenv file
+type dotenvParser struct {
+	src  string
+	pos  int
+	line int
+}
+
+func (p *dotenvParser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *dotenvParser) peek() byte {
+	return p.src[p.pos]
+}
+
+func (p *dotenvParser) advance() byte {
+	c := p.src[p.pos]
+	p.pos++
+	if c == '\n' {
+		p.line++
+	}
+	return c
+}
+
+// skipSpaces skips spaces and tabs but not line breaks
+func (p *dotenvParser) skipSpaces() {
+	for !p.eof() && (p.peek() == ' ' || p.peek() == '\t') {
+		p.advance()
+	}
+}
+
+// skipLine skips the rest of the current line including the line break
+func (p *dotenvParser) skipLine() {
+	for !p.eof() && p.advance() != '\n' {
+	}
+}
+
+func (p *dotenvParser) skipBlankAndComments() {
+	for !p.eof() {
+		switch p.peek() {
+		case ' ', '\t', '\n':
+			p.advance()
+		case '#':
+			p.skipLine()
+		default:
+			return
+		}
+	}
+}
+
+// assignment parses one KEY=VALUE statement and the rest of its line
+func (p *dotenvParser) assignment() (string, string, error) {
+	key := p.key()
+	if key == "export" && !p.eof() && (p.peek() == ' ' || p.peek() == '\t') {
+		p.skipSpaces()
+		key = p.key()
+	}
+	if key == "" {
+		return "", "", fmt.Errorf("expected a variable name")
+	}
+
+	p.skipSpaces()
+	if p.eof() || p.peek() != '=' {
+		return "", "", fmt.Errorf("expected '=' after %s", key)
+	}
+	p.advance()
+	p.skipSpaces()
+
+	var value string
+	var err error
+	switch {
+	case p.eof():
+		return key, "", nil
+	case p.peek() == '\'':
+		value, err = p.quoted('\'')
+	case p.peek() == '"':
+		value, err = p.quoted('"')
+	default:
+		return key, p.unquoted(), nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	p.skipSpaces()
+	if !p.eof() && p.peek() != '\n' && p.peek() != '#' {
+		return "", "", fmt.Errorf("unexpected characters after the value of %s", key)
+	}
+	p.skipLine()
+	return key, value, nil
+}
+
+// key reads a variable name
+func (p *dotenvParser) key() string {
+	start := p.pos
+	for !p.eof() && isDotenvKeyChar(p.peek()) {
+		p.advance()
+	}
+	return p.src[start:p.pos]
+}
+
+func isDotenvKeyChar(c byte) bool {
+	return c == '_' || c == '.' || c == '-' ||
+		(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// unquoted reads a value up to the end of the line or an inline comment
+func (p *dotenvParser) unquoted() string {
+	start := p.pos
+	for !p.eof() && p.peek() != '\n' {
+		if p.peek() == '#' && p.pos > start && (p.src[p.pos-1] == ' ' || p.src[p.pos-1] == '\t') {
+			break
+		}
+		p.advance()
+	}
+	value := strings.TrimSpace(p.src[start:p.pos])
+	p.skipLine()
+	return value
+}
+
+// quoted reads a quoted value; only double-quoted values process escapes
+func (p *dotenvParser) quoted(quote byte) (string, error) {
+	start := p.line
+	p.advance()
+
+	var value strings.Builder
+	for !p.eof() {
+		c := p.advance()
+		switch {
+		case c == quote:
+			return value.String(), nil
+		case c == '\\' && quote == '"' && !p.eof():
+			value.WriteString(dotenvEscape(p.advance()))
+		default:
+			value.WriteByte(c)
+		}
+	}
+	return "", fmt.Errorf("unterminated quoted value starting on line %d", start)
+}
+
+// dotenvEscape resolves the character following a backslash
+func dotenvEscape(c byte) string {
+	switch c {
+	case 'n':
+		return "\n"
+	case 'r':
+		return "\r"
+	case 't':
+		return "\t"
+	case '"', '\\', '$':
+		return string(c)
+	default:
+		return `\` + string(c)
+	}
+}
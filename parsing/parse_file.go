@@ -1,45 +1,343 @@
 package parsing
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path"
-
-	"gopkg.in/yaml.v3"
+	"sort"
 )
 
-func ParseFile(filepath string) (map[string]interface{}, error) {
+// Options controls how files are parsed
+type Options struct {
+	// Format forces the input format instead of detecting it, see Formats
+	Format string
+	// FormatHint is the format tried first when the format is recognised by
+	// the content, such as the format of the file compared with
+	FormatHint string
+	// LenientJSON accepts comments, trailing commas, single-quoted strings
+	// and unquoted keys in .json files, as always done for .jsonc and .json5
+	LenientJSON bool
+	// NestProperties turns dotted keys of .properties files into nested maps
+	NestProperties bool
+	// MultiDocument keys YAML documents by their identity even when a stream
+	// holds a single document. Streams with several documents always are.
+	MultiDocument bool
+	// Identity lists the dotted paths that identify a YAML document,
+	// DefaultIdentity when empty
+	Identity []string
+	// Stdin is read for the path "-", os.Stdin when nil
+	Stdin io.Reader
+	// Strict rejects JSON and YAML files defining a key twice in an object
+	Strict bool
+	// Warnings receives a line for every duplicate key found when Strict is
+	// not set. Warnings are discarded when it is nil.
+	Warnings io.Writer
+	// ExpandEnv replaces ${VAR}, ${VAR:-default} and ${VAR:?message}
+	// placeholders in the string values of parsed files
+	ExpandEnv bool
+	// Env holds the variables ExpandEnv resolves, the process environment
+	// when nil. See LoadEnvFile.
+	Env map[string]string
+	// ResolveRefs replaces JSON $ref objects and YAML !include tags pointing
+	// to other files by the content of these files
+	ResolveRefs bool
+
+	// refChain lists the files whose references are being resolved
+	refChain refChain
+}
+
+// StdinPath is the path that stands for the standard input
+const StdinPath = "-"
+
+// parser parses the content of a file in one input format into its root value
+type parser func(data []byte, opts Options) (interface{}, error)
+
+// parsers maps input format names to their parsers
+var parsers = map[string]parser{
+	"json": func(data []byte, opts Options) (interface{}, error) {
+		if opts.LenientJSON {
+			return parseLenientJSON(data)
+		}
+		return parseJSON(data)
+	},
+	"jsonc": func(data []byte, _ Options) (interface{}, error) {
+		return parseLenientJSON(data)
+	},
+	"json5": func(data []byte, _ Options) (interface{}, error) {
+		return parseLenientJSON(data)
+	},
+	"yaml": parseYAML,
+	"toml": func(data []byte, _ Options) (interface{}, error) {
+		return parseTOML(data)
+	},
+	"ini": func(data []byte, _ Options) (interface{}, error) {
+		return parseINI(data)
+	},
+	"dotenv": func(data []byte, _ Options) (interface{}, error) {
+		return parseDotenv(data)
+	},
+	"properties": func(data []byte, opts Options) (interface{}, error) {
+		return parseProperties(data, opts.NestProperties)
+	},
+	"xml": func(data []byte, _ Options) (interface{}, error) {
+		return parseXML(data)
+	},
+}
+
+// locator records the positions of the keys and values of a parsed document
+type locator func(doc *Document, data []byte, opts Options) error
+
+// locators maps input format names to the locators of their positions
+var locators = map[string]locator{
+	"json": func(doc *Document, data []byte, opts Options) error {
+		if opts.LenientJSON {
+			return locateLenientJSON(doc, data)
+		}
+		return locateJSON(doc, data)
+	},
+	"jsonc": func(doc *Document, data []byte, _ Options) error {
+		return locateLenientJSON(doc, data)
+	},
+	"json5": func(doc *Document, data []byte, _ Options) error {
+		return locateLenientJSON(doc, data)
+	},
+	"yaml": locateYAML,
+}
+
+// extensionFormats maps file extensions to input formats
+var extensionFormats = map[string]string{
+	".json":       "json",
+	".jsonc":      "jsonc",
+	".json5":      "json5",
+	".yaml":       "yaml",
+	".yml":        "yaml",
+	".toml":       "toml",
+	".ini":        "ini",
+	".cfg":        "ini",
+	".conf":       "ini",
+	".env":        "dotenv",
+	".properties": "properties",
+	".xml":        "xml",
+}
+
+// Formats returns the sorted names of the supported input formats
+func Formats() []string {
+	names := make([]string, 0, len(parsers))
+	for name := range parsers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// FormatFromPath detects the input format from the file name and extension,
+// ignoring the extension of compressed files. It returns an empty string
+// when the name gives no hint.
+func FormatFromPath(filepath string) string {
+	filepath = trimCompressionExt(filepath)
+	if isDotenvName(path.Base(filepath)) {
+		return "dotenv"
+	}
 	ext := path.Ext(filepath)
-	data, err := os.ReadFile(filepath)
+	if format, ok := extensionFormats[ext]; ok {
+		return format
+	}
+	if unitExtensions[ext] {
+		return "ini"
+	}
+	return ""
+}
+
+// ParseFile parses a configuration file with the default options
+func ParseFile(filepath string) (map[string]interface{}, error) {
+	return ParseFileWithOptions(filepath, Options{})
+}
+
+// ParseFileWithOptions parses a configuration file in opts.Format or, when
+// it is empty, in the format given by the file name. Files whose name gives
+// no hint, such as "-" for stdin or /dev/fd/N from process substitution,
+// are recognised by their content. The root of the file must be an object,
+// ParseDocument accepts any root value.
+func ParseFileWithOptions(filepath string, opts Options) (map[string]interface{}, error) {
+	doc, err := ParseDocument(filepath, opts)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read file: %w", err)
+		return nil, err
 	}
-	switch ext {
-	case ".json":
-		return parseJSON(data)
-	case ".yaml", ".yml":
-		return parseYAML(data)
+	return rootObject(doc.Data)
+}
+
+// ParseDocument parses a configuration file like ParseFileWithOptions and
+// records the position of every key and value. The root of JSON and YAML
+// documents may be an array or a scalar as well as an object. Positions are
+// only known for JSON and YAML files, other formats return a document
+// without them. Duplicate keys are detected in the same files and reported
+// as opts.Strict and opts.Warnings ask.
+//
+// A file inside a tar or zip archive is read with a path such as
+// release.tar.gz!config/app.yaml. The path of a whole archive gives a
+// document mapping the path of each file it holds to its content.
+func ParseDocument(filepath string, opts Options) (*Document, error) {
+	if filepath != StdinPath && archiveKind(filepath) != "" {
+		return parseArchive(filepath, opts)
+	}
+	data, err := readInput(filepath, opts)
+	if err != nil {
+		return nil, err
 	}
-	return nil, fmt.Errorf("unsupported file extension: %s", ext)
+	return parseDocument(data, filepath, opts)
 }
 
-func parseJSON(jsonData []byte) (map[string]interface{}, error) {
-	var result map[string]interface{}
-	err := json.Unmarshal(jsonData, &result)
+// parseDocument parses the content of the file at filepath into a document
+func parseDocument(data []byte, filepath string, opts Options) (*Document, error) {
+	format, result, err := parseContent(data, filepath, opts)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse json: %w", err)
+		return nil, err
 	}
 
-	return result, nil
+	doc := newDocument(result)
+	if locate, ok := locators[format]; ok {
+		if err := locate(doc, data, opts); err != nil {
+			return nil, err
+		}
+	}
+	if opts.ExpandEnv {
+		if doc.Data, err = expandEnv(doc.Data, doc.plain, opts.Env); err != nil {
+			return nil, fmt.Errorf("%s: %w", displayName(filepath), err)
+		}
+	}
+	if opts.ResolveRefs {
+		if err := resolveRefs(doc, filepath, opts); err != nil {
+			return nil, err
+		}
+	}
+	doc.setFile(displayName(filepath))
+	if err := reportDuplicates(doc, opts); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// reportDuplicates returns the duplicate keys of doc as an error in strict
+// mode and writes them to opts.Warnings otherwise
+func reportDuplicates(doc *Document, opts Options) error {
+	if opts.Strict {
+		errs := make([]error, len(doc.Duplicates))
+		for i, duplicate := range doc.Duplicates {
+			errs[i] = duplicate
+		}
+		return errors.Join(errs...)
+	}
+	if opts.Warnings != nil {
+		for _, duplicate := range doc.Duplicates {
+			if _, err := fmt.Fprintf(opts.Warnings, "warning: %s\n", duplicate); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// displayName returns the name positions report for a file
+func displayName(filepath string) string {
+	if filepath == StdinPath {
+		return "<stdin>"
+	}
+	return filepath
+}
+
+// parseContent parses the content of a file, returning its format too
+func parseContent(data []byte, filepath string, opts Options) (string, interface{}, error) {
+	format := opts.Format
+	if format == "" {
+		format = FormatFromPath(filepath)
+	}
+	if format == "" {
+		format, result, err := parseSniffed(data, opts)
+		if err != nil {
+			if ext := path.Ext(trimCompressionExt(filepath)); ext != "" && filepath != StdinPath {
+				return "", nil, fmt.Errorf("unsupported file extension: %s: %w", ext, err)
+			}
+			return "", nil, fmt.Errorf("%s: %w", filepath, err)
+		}
+		return format, result, nil
+	}
+	result, err := parseValue(data, format, opts)
+	if err != nil {
+		return "", nil, err
+	}
+	return format, result, nil
+}
+
+// readInput reads a file, a file inside an archive or the standard input
+// for StdinPath, and decompresses it when it is compressed with gzip, bzip2
+// or zlib
+func readInput(filepath string, opts Options) ([]byte, error) {
+	if archive, member, ok := splitArchivePath(filepath); ok {
+		return readArchiveMember(archive, member)
+	}
+	if filepath == StdinPath {
+		stdin := opts.Stdin
+		if stdin == nil {
+			stdin = os.Stdin
+		}
+		data, err := readDecompressed(stdin, filepath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read stdin: %w", err)
+		}
+		return data, nil
+	}
+
+	file, err := os.Open(filepath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	defer file.Close()
+	data, err := readDecompressed(file, filepath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	return data, nil
 }
 
-func parseYAML(yamlData []byte) (map[string]interface{}, error) {
-	var result map[string]interface{}
-	err := yaml.Unmarshal(yamlData, &result)
+// readDecompressed reads r to the end, decompressing its content on the way
+func readDecompressed(r io.Reader, filepath string) ([]byte, error) {
+	reader, err := decompress(r, filepath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse yaml: %w", err)
+		return nil, fmt.Errorf("failed to decompress: %w", err)
 	}
+	return io.ReadAll(reader)
+}
 
-	return result, nil
+// ParseData parses data whose root is an object in the named input format
+func ParseData(data []byte, format string, opts Options) (map[string]interface{}, error) {
+	result, err := parseValue(data, format, opts)
+	if err != nil {
+		return nil, err
+	}
+	return rootObject(result)
+}
+
+// parseValue parses data in the named input format, whatever its root
+func parseValue(data []byte, format string, opts Options) (interface{}, error) {
+	parse, ok := parsers[format]
+	if !ok {
+		return nil, fmt.Errorf("unsupported input format: %s", format)
+	}
+	return parse(data, opts)
+}
+
+// rootObject returns the root of a document, which must be an object or empty
+func rootObject(root interface{}) (map[string]interface{}, error) {
+	switch v := root.(type) {
+	case nil:
+		return nil, nil
+	case map[string]interface{}:
+		return v, nil
+	case []interface{}:
+		return nil, fmt.Errorf("document root is an array, not an object")
+	default:
+		return nil, fmt.Errorf("document root is a scalar, not an object")
+	}
 }
@@ -1,7 +1,17 @@
 package parsing
 
 import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
 	"code/helpers"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -81,7 +91,7 @@ func TestParseYAML(t *testing.T) {
 		{
 			name:     "valid yaml",
 			filepath: helpers.CreateTempYAML(t, "key: value\nnumber: 42"),
-			want:     map[string]interface{}{"key": "value", "number": 42},
+			want:     map[string]interface{}{"key": "value", "number": float64(42)},
 		},
 		{
 			name:     "empty yaml",
@@ -97,7 +107,7 @@ func TestParseYAML(t *testing.T) {
 			name:     "yaml with array",
 			filepath: helpers.CreateTempYAML(t, "list:\n  - 1\n  - 2\n  - 3"),
 			want: map[string]interface{}{
-				"list": []interface{}{1, 2, 3},
+				"list": []interface{}{float64(1), float64(2), float64(3)},
 			},
 		},
 		{
@@ -138,3 +148,1261 @@ func TestParseYAML(t *testing.T) {
 		})
 	}
 }
+
+func TestParseTOML(t *testing.T) {
+	tests := []struct {
+		name     string
+		filepath string
+		want     map[string]interface{}
+		wantErr  bool
+	}{
+		{
+			name:     "scalars",
+			filepath: helpers.CreateTempFile(t, "*.toml", "key = \"value\"\nnumber = 42\nratio = 0.5\nenabled = true"),
+			want:     map[string]interface{}{"key": "value", "number": float64(42), "ratio": 0.5, "enabled": true},
+		},
+		{
+			name:     "tables and inline tables",
+			filepath: helpers.CreateTempFile(t, "*.toml", "[server]\nhost = \"localhost\"\nlimits = { cpu = 2 }"),
+			want: map[string]interface{}{
+				"server": map[string]interface{}{
+					"host":   "localhost",
+					"limits": map[string]interface{}{"cpu": float64(2)},
+				},
+			},
+		},
+		{
+			name:     "arrays of tables",
+			filepath: helpers.CreateTempFile(t, "*.toml", "[[route]]\npath = \"/a\"\n[[route]]\npath = \"/b\""),
+			want: map[string]interface{}{
+				"route": []interface{}{
+					map[string]interface{}{"path": "/a"},
+					map[string]interface{}{"path": "/b"},
+				},
+			},
+		},
+		{
+			name: "datetimes",
+			filepath: helpers.CreateTempFile(t, "*.toml",
+				"offset = 2024-01-02T03:04:05+02:00\nutc = 2024-01-02T03:04:05Z\n"+
+					"local = 2024-01-02T03:04:05\ndate = 2024-01-02\ntime = 03:04:05.5"),
+			want: map[string]interface{}{
+				"offset": "2024-01-02T03:04:05+02:00",
+				"utc":    "2024-01-02T03:04:05Z",
+				"local":  "2024-01-02T03:04:05",
+				"date":   "2024-01-02",
+				"time":   "03:04:05.5",
+			},
+		},
+		{
+			name:     "invalid toml",
+			filepath: helpers.CreateTempFile(t, "*.toml", "key = "),
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseFile(tt.filepath)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, len(tt.want), len(got))
+			for k, v := range tt.want {
+				assert.Contains(t, got, k)
+				assert.True(t, helpers.DeepEqual(got[k], v), "key %s: got %#v, want %#v", k, got[k], v)
+			}
+		})
+	}
+}
+
+func TestParseDatetimesAcrossFormats(t *testing.T) {
+	fromTOML, err := ParseFile(helpers.CreateTempFile(t, "*.toml", "at = 2024-01-02T03:04:05Z\nday = 2024-01-02"))
+	require.NoError(t, err)
+	fromYAML, err := ParseFile(helpers.CreateTempYAML(t, "at: 2024-01-02T03:04:05Z\nday: 2024-01-02"))
+	require.NoError(t, err)
+	fromJSON, err := ParseFile(helpers.CreateTempJSON(t, `{"at": "2024-01-02T03:04:05Z", "day": "2024-01-02"}`))
+	require.NoError(t, err)
+
+	assert.Equal(t, fromJSON, fromTOML)
+	assert.Equal(t, fromJSON, fromYAML)
+}
+
+func TestParseNumbersAcrossFormats(t *testing.T) {
+	fromTOML, err := ParseFile(helpers.CreateTempFile(t, "*.toml", "port = 8080\nratio = 0.5\nlist = [1, 2]\n[1]\nx = 1"))
+	require.NoError(t, err)
+	fromYAML, err := ParseFile(helpers.CreateTempYAML(t, "port: 8080\nratio: 0.5\nlist: [1, 2]\n1: {x: 1}"))
+	require.NoError(t, err)
+	fromJSON, err := ParseFile(helpers.CreateTempJSON(t, `{"port": 8080, "ratio": 0.5, "list": [1, 2], "1": {"x": 1}}`))
+	require.NoError(t, err)
+
+	assert.Equal(t, fromJSON, fromTOML)
+	assert.Equal(t, fromJSON, fromYAML)
+
+	big := map[string]interface{}{"big": int64(9007199254740993), "huge": uint64(18446744073709551615), "exact": float64(1 << 60)}
+	bigTOML, err := ParseFile(helpers.CreateTempFile(t, "*.toml", "big = 9007199254740993\nexact = 1152921504606846976\n"))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"big": big["big"], "exact": big["exact"]}, bigTOML)
+	bigYAML, err := ParseFile(helpers.CreateTempYAML(t, "big: 9007199254740993\nhuge: 18446744073709551615\nexact: 1152921504606846976\n"))
+	require.NoError(t, err)
+	assert.Equal(t, big, bigYAML)
+	bigJSON, err := ParseFile(helpers.CreateTempJSON(t, `{"big": 9007199254740993, "huge": 18446744073709551615, "exact": 1152921504606846976}`))
+	require.NoError(t, err)
+	assert.Equal(t, big, bigJSON)
+
+	assert.Equal(t, "3600000", FormatNumber(3600000))
+	assert.Equal(t, "0.5", FormatNumber(0.5))
+}
+
+func TestParseINI(t *testing.T) {
+	tests := []struct {
+		name     string
+		filepath string
+		want     map[string]interface{}
+		wantErr  bool
+	}{
+		{
+			name: "sections and global keys",
+			filepath: helpers.CreateTempFile(t, "*.ini",
+				"; global settings\nname = app\n\n[database]\n# connection\nhost = localhost\nport: 5432\n"),
+			want: map[string]interface{}{
+				"name":     "app",
+				"database": map[string]interface{}{"host": "localhost", "port": "5432"},
+			},
+		},
+		{
+			name:     "repeated keys and sections",
+			filepath: helpers.CreateTempFile(t, "*.cfg", "[s]\nopt = a\nopt = b\n[t]\nx = 1\n[s]\nopt = c\nflag"),
+			want: map[string]interface{}{
+				"s": map[string]interface{}{"opt": []interface{}{"a", "b", "c"}, "flag": ""},
+				"t": map[string]interface{}{"x": "1"},
+			},
+		},
+		{
+			name: "systemd unit with continuation",
+			filepath: helpers.CreateTempFile(t, "*.service",
+				"[Unit]\nDescription=Web\nAfter=network.target\nAfter=db.service\n\n"+
+					"[Service]\nExecStart=/usr/bin/web \\\n    --port 80 \\\n    --verbose\nEnvironment=A=1\n"),
+			want: map[string]interface{}{
+				"Unit": map[string]interface{}{
+					"Description": "Web",
+					"After":       []interface{}{"network.target", "db.service"},
+				},
+				"Service": map[string]interface{}{
+					"ExecStart":   "/usr/bin/web --port 80 --verbose",
+					"Environment": "A=1",
+				},
+			},
+		},
+		{
+			name:     "conf extension",
+			filepath: helpers.CreateTempFile(t, "*.conf", "[mysqld]\nskip-name-resolve"),
+			want:     map[string]interface{}{"mysqld": map[string]interface{}{"skip-name-resolve": ""}},
+		},
+		{
+			name:     "unterminated section",
+			filepath: helpers.CreateTempFile(t, "*.ini", "[broken\nkey = value"),
+			wantErr:  true,
+		},
+		{
+			name:     "missing key",
+			filepath: helpers.CreateTempFile(t, "*.ini", "= value"),
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseFile(tt.filepath)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseDotenv(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name, content string) string {
+		filepath := dir + "/" + name
+		require.NoError(t, os.WriteFile(filepath, []byte(content), 0o600))
+		return filepath
+	}
+
+	tests := []struct {
+		name     string
+		filepath string
+		want     map[string]interface{}
+		wantErr  bool
+	}{
+		{
+			name: "plain values and comments",
+			filepath: writeFile(".env",
+				"# database\nDB_HOST=localhost\nDB_PORT = 5432 # inline comment\nURL=http://x/#anchor\nEMPTY=\n"),
+			want: map[string]interface{}{
+				"DB_HOST": "localhost",
+				"DB_PORT": "5432",
+				"URL":     "http://x/#anchor",
+				"EMPTY":   "",
+			},
+		},
+		{
+			name:     "export prefix and quotes",
+			filepath: writeFile(".env.production", "export TOKEN='a $b \\n'\nexport GREETING=\"say \\\"hi\\\"\\tnow\" # done\n"),
+			want: map[string]interface{}{
+				"TOKEN":    `a $b \n`,
+				"GREETING": "say \"hi\"\tnow",
+			},
+		},
+		{
+			name:     "multiline double-quoted value",
+			filepath: writeFile("app.env", "KEY=\"-----BEGIN-----\nabc\n-----END-----\"\nNEXT=1\r\n"),
+			want: map[string]interface{}{
+				"KEY":  "-----BEGIN-----\nabc\n-----END-----",
+				"NEXT": "1",
+			},
+		},
+		{
+			name:     "later assignment wins",
+			filepath: writeFile(".env.local", "A=1\nA=2"),
+			want:     map[string]interface{}{"A": "2"},
+		},
+		{
+			name:     "missing equals sign",
+			filepath: writeFile(".env.broken", "JUST_A_KEY\n"),
+			wantErr:  true,
+		},
+		{
+			name:     "unterminated quote",
+			filepath: writeFile(".env.unterminated", "A=\"open\n"),
+			wantErr:  true,
+		},
+		{
+			name:     "garbage after quoted value",
+			filepath: writeFile(".env.garbage", "A='x' y\n"),
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseFile(tt.filepath)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseProperties(t *testing.T) {
+	const properties = "# comment\n! another comment\n" +
+		"db.host=localhost\n" +
+		"db.port : 5432\n" +
+		"db.pool.size   10\n" +
+		"key\\ with\\ spaces = \\u00e9t\\u00e9\n" +
+		"fruits = apple, \\\n    banana, \\\n    cherry\n" +
+		"path=c:\\\\temp\\tdir\n" +
+		"emoji=\\uD83D\\uDE00\n" +
+		"empty\n"
+
+	tests := []struct {
+		name     string
+		filepath string
+		opts     Options
+		want     map[string]interface{}
+		wantErr  bool
+	}{
+		{
+			name:     "flat keys",
+			filepath: helpers.CreateTempFile(t, "*.properties", properties),
+			want: map[string]interface{}{
+				"db.host":         "localhost",
+				"db.port":         "5432",
+				"db.pool.size":    "10",
+				"key with spaces": "été",
+				"fruits":          "apple, banana, cherry",
+				"path":            "c:\\temp\tdir",
+				"emoji":           "😀",
+				"empty":           "",
+			},
+		},
+		{
+			name:     "nested keys",
+			filepath: helpers.CreateTempFile(t, "*.properties", "db.host=localhost\ndb.pool.size=10\nname=app"),
+			opts:     Options{NestProperties: true},
+			want: map[string]interface{}{
+				"db": map[string]interface{}{
+					"host": "localhost",
+					"pool": map[string]interface{}{"size": "10"},
+				},
+				"name": "app",
+			},
+		},
+		{
+			name:     "nested key conflicts with value",
+			filepath: helpers.CreateTempFile(t, "*.properties", "db=1\ndb.host=localhost"),
+			opts:     Options{NestProperties: true},
+			wantErr:  true,
+		},
+		{
+			name:     "malformed unicode escape",
+			filepath: helpers.CreateTempFile(t, "*.properties", "key=\\u12"),
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseFileWithOptions(tt.filepath, tt.opts)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseXML(t *testing.T) {
+	tests := []struct {
+		name     string
+		filepath string
+		want     map[string]interface{}
+		wantErr  bool
+	}{
+		{
+			name: "maven pom",
+			filepath: helpers.CreateTempFile(t, "*.xml", `<?xml version="1.0"?>
+<!-- build -->
+<project xmlns="http://maven.apache.org/POM/4.0.0">
+  <artifactId>app</artifactId>
+  <dependencies>
+    <dependency><artifactId>a</artifactId><scope>test</scope></dependency>
+    <dependency><artifactId>b</artifactId></dependency>
+  </dependencies>
+  <packaging/>
+</project>`),
+			want: map[string]interface{}{
+				"project": map[string]interface{}{
+					"@xmlns":     "http://maven.apache.org/POM/4.0.0",
+					"artifactId": "app",
+					"dependencies": map[string]interface{}{
+						"dependency": []interface{}{
+							map[string]interface{}{"artifactId": "a", "scope": "test"},
+							map[string]interface{}{"artifactId": "b"},
+						},
+					},
+					"packaging": "",
+				},
+			},
+		},
+		{
+			name: "attributes, text and namespaces",
+			filepath: helpers.CreateTempFile(t, "*.xml", `<beans xmlns:p="urn:p">
+  <bean id="ds" p:url="jdbc:x" xml:lang="en">  pooled <![CDATA[<raw>]]> </bean>
+  <p:extra>1</p:extra>
+</beans>`),
+			want: map[string]interface{}{
+				"beans": map[string]interface{}{
+					"@xmlns:p": "urn:p",
+					"bean": map[string]interface{}{
+						"@id":       "ds",
+						"@p:url":    "jdbc:x",
+						"@xml:lang": "en",
+						"#text":     "pooled <raw>",
+					},
+					"p:extra": "1",
+				},
+			},
+		},
+		{
+			name:     "malformed xml",
+			filepath: helpers.CreateTempFile(t, "*.xml", "<a><b></a>"),
+			wantErr:  true,
+		},
+		{
+			name:     "no root element",
+			filepath: helpers.CreateTempFile(t, "*.xml", "<!-- empty -->"),
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseFile(tt.filepath)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseLenientJSON(t *testing.T) {
+	const lenient = `// settings
+{
+  /* editor */ "editor.fontSize": 14,
+  unquoted_key: 'single "quoted"',
+  $special: 'it\'s',
+  "list": [1, 2, 3,],
+  hex: 0xFF, plus: +1, half: .5, whole: 2., neg: -.25,
+  "long": 'line \
+continued', // trailing comment
+}`
+	want := map[string]interface{}{
+		"editor.fontSize": float64(14),
+		"unquoted_key":    `single "quoted"`,
+		"$special":        "it's",
+		"list":            []interface{}{float64(1), float64(2), float64(3)},
+		"hex":             float64(255),
+		"plus":            float64(1),
+		"half":            0.5,
+		"whole":           float64(2),
+		"neg":             -0.25,
+		"long":            "line continued",
+	}
+
+	tests := []struct {
+		name     string
+		filepath string
+		opts     Options
+		want     map[string]interface{}
+		wantErr  bool
+	}{
+		{
+			name:     "jsonc",
+			filepath: helpers.CreateTempFile(t, "*.jsonc", lenient),
+			want:     want,
+		},
+		{
+			name:     "json5",
+			filepath: helpers.CreateTempFile(t, "*.json5", lenient),
+			want:     want,
+		},
+		{
+			name:     "json in lenient mode",
+			filepath: helpers.CreateTempJSON(t, lenient),
+			opts:     Options{LenientJSON: true},
+			want:     want,
+		},
+		{
+			name:     "json in strict mode",
+			filepath: helpers.CreateTempJSON(t, lenient),
+			wantErr:  true,
+		},
+		{
+			name:     "comment markers inside strings",
+			filepath: helpers.CreateTempFile(t, "*.jsonc", `{"url": "http://x/*y*/", "c": "a,}"}`),
+			want:     map[string]interface{}{"url": "http://x/*y*/", "c": "a,}"},
+		},
+		{
+			name:     "unterminated block comment",
+			filepath: helpers.CreateTempFile(t, "*.jsonc", `{"a": 1 /* open`),
+			wantErr:  true,
+		},
+		{
+			name:     "infinity is not representable",
+			filepath: helpers.CreateTempFile(t, "*.json5", `{a: Infinity}`),
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseFileWithOptions(tt.filepath, tt.opts)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseYAMLMultiDocument(t *testing.T) {
+	const manifests = `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  namespace: prod
+  name: web
+spec:
+  replicas: 3
+---
+---
+replicas: 1
+---
+replicas: 2
+`
+
+	tests := []struct {
+		name     string
+		filepath string
+		opts     Options
+		want     map[string]interface{}
+	}{
+		{
+			name:     "documents keyed by resource identity",
+			filepath: helpers.CreateTempYAML(t, manifests),
+			want: map[string]interface{}{
+				"v1/Service/web": map[string]interface{}{
+					"apiVersion": "v1",
+					"kind":       "Service",
+					"metadata":   map[string]interface{}{"name": "web"},
+				},
+				"apps/v1/Deployment/prod/web": map[string]interface{}{
+					"apiVersion": "apps/v1",
+					"kind":       "Deployment",
+					"metadata":   map[string]interface{}{"namespace": "prod", "name": "web"},
+					"spec":       map[string]interface{}{"replicas": float64(3)},
+				},
+				"document 3": map[string]interface{}{"replicas": float64(1)},
+				"document 4": map[string]interface{}{"replicas": float64(2)},
+			},
+		},
+		{
+			name:     "custom identity with duplicates",
+			filepath: helpers.CreateTempYAML(t, manifests),
+			opts:     Options{Identity: []string{"metadata.name"}},
+			want: map[string]interface{}{
+				"web": map[string]interface{}{
+					"apiVersion": "v1",
+					"kind":       "Service",
+					"metadata":   map[string]interface{}{"name": "web"},
+				},
+				"web (2)": map[string]interface{}{
+					"apiVersion": "apps/v1",
+					"kind":       "Deployment",
+					"metadata":   map[string]interface{}{"namespace": "prod", "name": "web"},
+					"spec":       map[string]interface{}{"replicas": float64(3)},
+				},
+				"document 3": map[string]interface{}{"replicas": float64(1)},
+				"document 4": map[string]interface{}{"replicas": float64(2)},
+			},
+		},
+		{
+			name:     "single document stays plain",
+			filepath: helpers.CreateTempYAML(t, "---\nkind: Service\n"),
+			want:     map[string]interface{}{"kind": "Service"},
+		},
+		{
+			name:     "single document in multi-document mode",
+			filepath: helpers.CreateTempYAML(t, "kind: Service\n"),
+			opts:     Options{MultiDocument: true},
+			want: map[string]interface{}{
+				"Service": map[string]interface{}{"kind": "Service"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseFileWithOptions(tt.filepath, tt.opts)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseFileFormatDetection(t *testing.T) {
+	tests := []struct {
+		name     string
+		filepath string
+		opts     Options
+		want     map[string]interface{}
+		wantErr  bool
+	}{
+		{
+			name:     "sniffed json",
+			filepath: helpers.CreateTempFile(t, "config*", `{"key": "value"}`),
+			want:     map[string]interface{}{"key": "value"},
+		},
+		{
+			name:     "sniffed yaml",
+			filepath: helpers.CreateTempFile(t, "config*", "key: value\nlist:\n  - 1"),
+			want:     map[string]interface{}{"key": "value", "list": []interface{}{float64(1)}},
+		},
+		{
+			name:     "sniffed toml",
+			filepath: helpers.CreateTempFile(t, "config*", "[server]\nport = 80"),
+			want:     map[string]interface{}{"server": map[string]interface{}{"port": float64(80)}},
+		},
+		{
+			name:     "sniffed ini",
+			filepath: helpers.CreateTempFile(t, "config*", "[server]\nport = eighty"),
+			want:     map[string]interface{}{"server": map[string]interface{}{"port": "eighty"}},
+		},
+		{
+			name:     "sniffed with a hint",
+			filepath: helpers.CreateTempFile(t, "config*", "[server]\nhost = \"a\"\nport = 80"),
+			opts:     Options{FormatHint: "ini"},
+			want:     map[string]interface{}{"server": map[string]interface{}{"host": `"a"`, "port": "80"}},
+		},
+		{
+			name:     "sniffed dotenv",
+			filepath: helpers.CreateTempFile(t, "config*", "export HOST=localhost\nPORT=eighty"),
+			want:     map[string]interface{}{"HOST": "localhost", "PORT": "eighty"},
+		},
+		{
+			name:     "sniffed xml",
+			filepath: helpers.CreateTempFile(t, "config*", "<config><port>80</port></config>"),
+			want:     map[string]interface{}{"config": map[string]interface{}{"port": "80"}},
+		},
+		{
+			name:     "unknown extension sniffed",
+			filepath: helpers.CreateTempFile(t, "Dockerfile*.tmpl", `{"key": "value"}`),
+			want:     map[string]interface{}{"key": "value"},
+		},
+		{
+			name:     "explicit format overrides extension",
+			filepath: helpers.CreateTempFile(t, "config*.json", "key: value"),
+			opts:     Options{Format: "yaml"},
+			want:     map[string]interface{}{"key": "value"},
+		},
+		{
+			name:     "unsupported explicit format",
+			filepath: helpers.CreateTempFile(t, "config*", "key: value"),
+			opts:     Options{Format: "csv"},
+			wantErr:  true,
+		},
+		{
+			name:     "plain text is not sniffed",
+			filepath: helpers.CreateTempFile(t, "config*", "just some words"),
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseFileWithOptions(tt.filepath, tt.opts)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestFormatFromPath(t *testing.T) {
+	tests := map[string]string{
+		"config.json":            "json",
+		"settings.jsonc":         "jsonc",
+		"values.yml":             "yaml",
+		"/etc/app/.env":          "dotenv",
+		"/etc/app/.env.staging":  "dotenv",
+		"web.service":            "ini",
+		"application.properties": "properties",
+		"Dockerfile.json.tmpl":   "",
+		"config":                 "",
+		"snapshot.json.gz":       "json",
+		"archive/.env.zz":        "dotenv",
+		"config.gz":              "",
+	}
+	for filepath, want := range tests {
+		assert.Equal(t, want, FormatFromPath(filepath), filepath)
+	}
+}
+
+func TestParseFileFromStdin(t *testing.T) {
+	tests := []struct {
+		name    string
+		stdin   string
+		opts    Options
+		want    map[string]interface{}
+		wantErr bool
+	}{
+		{
+			name:  "sniffed yaml",
+			stdin: "kind: Service\nmetadata:\n  name: web\n",
+			want: map[string]interface{}{
+				"kind":     "Service",
+				"metadata": map[string]interface{}{"name": "web"},
+			},
+		},
+		{
+			name:  "explicit format",
+			stdin: "PORT=80",
+			opts:  Options{Format: "properties"},
+			want:  map[string]interface{}{"PORT": "80"},
+		},
+		{
+			name:    "unrecognised content",
+			stdin:   "plain words",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Stdin = strings.NewReader(tt.stdin)
+			got, err := ParseFileWithOptions(StdinPath, tt.opts)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseFileFromFileDescriptor(t *testing.T) {
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, r.Close())
+	}()
+
+	_, err = w.WriteString(`{"key": "value"}`)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	got, err := ParseFile(fmt.Sprintf("/dev/fd/%d", r.Fd()))
+	if errors.Is(err, os.ErrNotExist) {
+		t.Skip("/dev/fd is not available on this platform")
+	}
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"key": "value"}, got)
+}
+
+func TestParseYAMLFeatures(t *testing.T) {
+	tests := []struct {
+		name     string
+		filepath string
+		want     map[string]interface{}
+		wantErr  bool
+	}{
+		{
+			name: "anchors, aliases and merge keys",
+			filepath: helpers.CreateTempYAML(t, `defaults: &defaults
+  timeout: 30
+  retries: 3
+extra: &extra
+  retries: 5
+  verbose: true
+service:
+  <<: [*defaults, *extra]
+  timeout: 60
+hosts: &hosts [a, b]
+backup:
+  hosts: *hosts
+`),
+			want: map[string]interface{}{
+				"defaults": map[string]interface{}{"timeout": float64(30), "retries": float64(3)},
+				"extra":    map[string]interface{}{"retries": float64(5), "verbose": true},
+				"service":  map[string]interface{}{"timeout": float64(60), "retries": float64(3), "verbose": true},
+				"hosts":    []interface{}{"a", "b"},
+				"backup":   map[string]interface{}{"hosts": []interface{}{"a", "b"}},
+			},
+		},
+		{
+			name:     "non-string keys",
+			filepath: helpers.CreateTempYAML(t, "codes:\n  200: ok\n  0x1F4: error\n  true: yes\n  ~: none\n  1.5: half\n  ? [a, b]\n  : pair\n"),
+			want: map[string]interface{}{
+				"codes": map[string]interface{}{
+					"200":       "ok",
+					"500":       "error",
+					"true":      "yes",
+					"null":      "none",
+					"1.5":       "half",
+					`["a","b"]`: "pair",
+				},
+			},
+		},
+		{
+			name:     "custom tags",
+			filepath: helpers.CreateTempYAML(t, "password: !secret db-pass\nport: !!str 5432\nlist: !ordered [1, 2]\n"),
+			want: map[string]interface{}{
+				"password": TaggedValue{Tag: "!secret", Value: "db-pass"},
+				"port":     "5432",
+				"list":     TaggedValue{Tag: "!ordered", Value: []interface{}{float64(1), float64(2)}},
+			},
+		},
+		{
+			name:     "merge key referencing a scalar",
+			filepath: helpers.CreateTempYAML(t, "base: &b 1\nx:\n  <<: *b\n"),
+			wantErr:  true,
+		},
+		{
+			name:     "recursive alias",
+			filepath: helpers.CreateTempYAML(t, "a: &x [1, *x]\n"),
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseFile(tt.filepath)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseDocumentPositions(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name  string
+		file  string
+		data  string
+		opts  Options
+		paths map[string]Position
+	}{
+		{
+			name: "json",
+			file: "config.json",
+			data: "{\n  \"db\": {\"host\": \"a\",\n    \"ports\": [1, 2]},\n  \"a/b\": null\n}\n",
+			paths: map[string]Position{
+				"db":       {Line: 2, Column: 3},
+				"db.host":  {Line: 2, Column: 10},
+				"db.ports": {Line: 3, Column: 5},
+				"a/b":      {Line: 4, Column: 3},
+			},
+		},
+		{
+			name: "json5",
+			file: "config.json5",
+			data: "// comment\n{\n  /* block */ host: 'a',\n  port: 0x10, // port\n}\n",
+			paths: map[string]Position{
+				"host": {Line: 3, Column: 15},
+				"port": {Line: 4, Column: 3},
+			},
+		},
+		{
+			name: "yaml with alias",
+			file: "config.yaml",
+			data: "base: &base\n  host: a\nprod:\n  <<: *base\n  port: 80\n",
+			paths: map[string]Position{
+				"base.host": {Line: 2, Column: 3},
+				"prod.port": {Line: 5, Column: 3},
+				"prod.host": {Line: 3, Column: 1},
+			},
+		},
+		{
+			name: "yaml documents",
+			file: "stream.yaml",
+			data: "kind: A\nspec: 1\n---\nkind: B\nspec: 2\n",
+			paths: map[string]Position{
+				"A.spec": {Line: 2, Column: 1},
+				"B.spec": {Line: 5, Column: 1},
+			},
+			opts: Options{Identity: []string{"kind"}},
+		},
+		{
+			name:  "format without positions",
+			file:  "config.ini",
+			data:  "[db]\nhost = a\n",
+			paths: map[string]Position{"db.host": {}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := dir + "/" + tt.file
+			require.NoError(t, os.WriteFile(path, []byte(tt.data), 0o600))
+
+			doc, err := ParseDocument(path, tt.opts)
+			require.NoError(t, err)
+			for dotted, want := range tt.paths {
+				if want.IsValid() {
+					want.File = path
+				}
+				assert.Equal(t, want, doc.Position(strings.Split(dotted, ".")), dotted)
+			}
+		})
+	}
+}
+
+func TestPointer(t *testing.T) {
+	assert.Equal(t, "", Pointer(nil))
+	assert.Equal(t, "/a~1b/c~0d/0", Pointer([]string{"a/b", "c~d", "0"}))
+}
+
+func TestParseDocumentRoots(t *testing.T) {
+	tests := []struct {
+		name   string
+		stdin  string
+		format string
+		want   interface{}
+	}{
+		{name: "json array", stdin: `[{"path": "/"}, 2]`, want: []interface{}{map[string]interface{}{"path": "/"}, float64(2)}},
+		{name: "json scalar", stdin: `"on"`, format: "json", want: "on"},
+		{name: "yaml sequence", stdin: "- a\n- b\n", want: []interface{}{"a", "b"}},
+		{name: "yaml scalar", stdin: "42\n", format: "yaml", want: float64(42)},
+		{name: "empty yaml", stdin: "", format: "yaml", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := ParseDocument(StdinPath, Options{Format: tt.format, Stdin: strings.NewReader(tt.stdin)})
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, doc.Data)
+		})
+	}
+}
+
+func TestParseFileRejectsNonObjectRoot(t *testing.T) {
+	_, err := ParseFileWithOptions(StdinPath, Options{Stdin: strings.NewReader("[1, 2]")})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "document root is an array")
+
+	_, err = ParseFileWithOptions(StdinPath, Options{Stdin: strings.NewReader("plain words")})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unable to detect the input format")
+}
+
+func TestParseDuplicateKeys(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		data   string
+		want   []string
+	}{
+		{
+			name:   "json",
+			format: "json",
+			data:   "{\"a\": 1,\n \"b\": {\"x\": 1, \"x\": 2},\n \"a\": 3}",
+			want: []string{
+				"<stdin>:2:16: duplicate key b.x, first defined at <stdin>:2:8",
+				"<stdin>:3:2: duplicate key a, first defined at <stdin>:1:2",
+			},
+		},
+		{
+			name:   "json5",
+			format: "json5",
+			data:   "{a: 1, // first\n a: 2}",
+			want:   []string{"<stdin>:2:2: duplicate key a, first defined at <stdin>:1:2"},
+		},
+		{
+			name:   "yaml",
+			format: "yaml",
+			data:   "base: &b {x: 1}\nitem:\n  <<: *b\n  x: 2\n  y: 1\n  y: 2\n",
+			want:   []string{"<stdin>:6:3: duplicate key item.y, first defined at <stdin>:5:3"},
+		},
+		{
+			name:   "no duplicates",
+			format: "json",
+			data:   `{"a": {"x": 1}, "b": {"x": 1}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var warnings strings.Builder
+			_, err := ParseFileWithOptions(StdinPath, Options{
+				Format:   tt.format,
+				Stdin:    strings.NewReader(tt.data),
+				Warnings: &warnings,
+			})
+			require.NoError(t, err)
+			var want strings.Builder
+			for _, line := range tt.want {
+				want.WriteString("warning: " + line + "\n")
+			}
+			assert.Equal(t, want.String(), warnings.String())
+
+			_, err = ParseFileWithOptions(StdinPath, Options{
+				Format: tt.format,
+				Stdin:  strings.NewReader(tt.data),
+				Strict: true,
+			})
+			if len(tt.want) == 0 {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Equal(t, strings.Join(tt.want, "\n"), err.Error())
+		})
+	}
+}
+
+// bzip2Config is "name: web\nport: 80\n" compressed with bzip2, which the
+// standard library can only decompress
+const bzip2Config = "\x42\x5a\x68\x39\x31\x41\x59\x26\x53\x59\x04\x9c\x7d\xb2\x00\x00\x06\xd9\x80\x00" +
+	"\x10\x40\x00\x40\x50\x32\x03\xd4\x80\x20\x00\x31\x4c\x00\x01\x4d\x34\xf5\x1a\x7a" +
+	"\x34\x9a\xe0\xc0\x0a\x0b\x1b\x99\x4c\xac\xfc\x5d\xc9\x14\xe1\x42\x40\x12\x71\xf6\xc8"
+
+func TestParseCompressedFiles(t *testing.T) {
+	compress := func(newWriter func(w io.Writer) io.WriteCloser, content string) string {
+		var buf bytes.Buffer
+		w := newWriter(&buf)
+		_, err := io.WriteString(w, content)
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+		return buf.String()
+	}
+	gzipped := func(content string) string {
+		return compress(func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }, content)
+	}
+	zlibbed := func(content string) string {
+		return compress(func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) }, content)
+	}
+	want := map[string]interface{}{"name": "web", "port": float64(80)}
+
+	tests := []struct {
+		name    string
+		pattern string
+		data    string
+		want    map[string]interface{}
+		wantErr bool
+	}{
+		{name: "gzip by extension", pattern: "*.yaml.gz", data: gzipped("name: web\nport: 80\n"), want: want},
+		{name: "gzip without extension", pattern: "snapshot-*", data: gzipped("name: web\nport: 80\n"), want: want},
+		{name: "bzip2", pattern: "*.yaml.bz2", data: bzip2Config, want: want},
+		{
+			name:    "zlib",
+			pattern: "*.json.zz",
+			data:    zlibbed(`{"name": "web", "port": 80}`),
+			want:    map[string]interface{}{"name": "web", "port": float64(80)},
+		},
+		{name: "corrupt gzip", pattern: "*.yaml.gz", data: "name: web\n", wantErr: true},
+		{name: "text looking like zlib", pattern: "*.yaml", data: "x^2: 1\n", want: map[string]interface{}{"x^2": float64(1)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseFile(helpers.CreateTempFile(t, tt.pattern, tt.data))
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseArchives(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"config/app.yaml": "port: 80\n",
+		"config/db.json":  `{"host": "a"}`,
+		"README":          "not a config file",
+	}
+
+	tarball := dir + "/release.tar.gz"
+	var tarData bytes.Buffer
+	gz := gzip.NewWriter(&tarData)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: "./" + name, Mode: 0o644, Size: int64(len(content))}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	require.NoError(t, os.WriteFile(tarball, tarData.Bytes(), 0o600))
+
+	zipball := dir + "/release.zip"
+	var zipData bytes.Buffer
+	zw := zip.NewWriter(&zipData)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	require.NoError(t, os.WriteFile(zipball, zipData.Bytes(), 0o600))
+
+	bang := dir + "/odd!name.json"
+	require.NoError(t, os.WriteFile(bang, []byte(`{"odd": true}`), 0o600))
+
+	whole := map[string]interface{}{
+		"config/app.yaml": map[string]interface{}{"port": float64(80)},
+		"config/db.json":  map[string]interface{}{"host": "a"},
+	}
+	tests := []struct {
+		name    string
+		path    string
+		want    interface{}
+		wantErr bool
+	}{
+		{name: "file in tarball", path: tarball + "!config/app.yaml", want: map[string]interface{}{"port": float64(80)}},
+		{name: "file in zip", path: zipball + "!./config/db.json", want: map[string]interface{}{"host": "a"}},
+		{name: "whole tarball", path: tarball, want: whole},
+		{name: "whole zip", path: zipball, want: whole},
+		{name: "missing file", path: zipball + "!config/missing.yaml", wantErr: true},
+		{name: "file named with separator", path: bang, want: map[string]interface{}{"odd": true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := ParseDocument(tt.path, Options{})
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, doc.Data)
+		})
+	}
+
+	doc, err := ParseDocument(tarball, Options{})
+	require.NoError(t, err)
+	assert.Equal(t, Position{File: tarball + "!config/app.yaml", Line: 1, Column: 1},
+		doc.Position([]string{"config/app.yaml", "port"}))
+}
+
+func TestExpandEnv(t *testing.T) {
+	env := map[string]string{"HOST": "db", "EMPTY": "", "NAME": "HOST"}
+	tests := []struct {
+		name    string
+		text    string
+		want    string
+		wantErr string
+	}{
+		{name: "plain variable", text: "host: ${HOST}", want: "host: db"},
+		{name: "unset variable", text: "host: ${MISSING}", want: "host: "},
+		{name: "default", text: "port: ${PORT:-8080}", want: "port: 8080"},
+		{name: "default for empty", text: "a: ${EMPTY:-x}, b: ${EMPTY-x}", want: "a: x, b: "},
+		{name: "nested default", text: "host: ${MISSING:-${HOST}}", want: "host: db"},
+		{name: "required", text: "host: ${HOST:?host is required}", want: "host: db"},
+		{name: "required unset", text: "${PORT:?port is required}", wantErr: "PORT: port is required"},
+		{name: "required empty", text: "${EMPTY:?}", wantErr: "EMPTY: is not set"},
+		{name: "escaped dollar", text: "price: $$5, raw: $${HOST}, bare: $HOST", want: "price: $5, raw: ${HOST}, bare: $HOST"},
+		{name: "unterminated", text: "host: ${HOST", wantErr: `unterminated placeholder "${HOST"`},
+		{name: "invalid name", text: "${1A}", wantErr: "invalid placeholder ${1A}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := expandEnv(tt.text, nil, env)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseFileExpandEnv(t *testing.T) {
+	envFile := helpers.CreateTempFile(t, "*.env", "export DB_HOST=db\nPORT=5432\nPW='a\"b'\nX=\"1\\nc: injected\"\n")
+	env, err := LoadEnvFile(envFile)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"DB_HOST": "db", "PORT": "5432", "PW": `a"b`, "X": "1\nc: injected"}, env)
+
+	opts := Options{ExpandEnv: true, Env: env}
+	file := helpers.CreateTempYAML(t, "host: ${DB_HOST}\nport: ${PORT:-80}\nquoted: '${PORT}'\n"+
+		"user:\n  - ${USER:-app}\na: ${X}\n")
+	got, err := ParseFileWithOptions(file, opts)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"host":   "db",
+		"port":   float64(5432),
+		"quoted": "5432",
+		"user":   []interface{}{"app"},
+		"a":      "1\nc: injected",
+	}, got)
+
+	aliased := helpers.CreateTempYAML(t, "base: &b\n  x: \"$${HOME}\"\n  port: ${PORT}\na: *b\nc:\n  <<: *b\n")
+	got, err = ParseFileWithOptions(aliased, opts)
+	require.NoError(t, err)
+	want := map[string]interface{}{"x": "${HOME}", "port": float64(5432)}
+	assert.Equal(t, map[string]interface{}{"base": want, "a": want, "c": want}, got)
+
+	jsonFile := helpers.CreateTempJSON(t, `{"pw": "${PW}", "b": 1}`)
+	doc, err := ParseDocument(jsonFile, opts)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"pw": `a"b`, "b": float64(1)}, doc.Data)
+	assert.Equal(t, Position{File: jsonFile, Line: 1, Column: 17}, doc.Position([]string{"b"}))
+
+	_, err = ParseFileWithOptions(helpers.CreateTempYAML(t, "db:\n  user: ${USER:?}\n"), opts)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "db.user: USER: is not set")
+}
+
+func TestParseResolveRefs(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := dir + "/" + name
+		require.NoError(t, os.MkdirAll(path[:strings.LastIndex(path, "/")], 0o700))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		return path
+	}
+	main := write("main.yaml", "db: !include parts/db.yaml\n"+
+		"limits:\n  $ref: parts/common.json#/limits\n"+
+		"local:\n  $ref: '#/db'\n"+
+		"remote:\n  $ref: https://example.com/schema.json\n")
+	write("parts/db.yaml", "host: a\nauth: !include secrets/auth.json\n")
+	write("parts/secrets/auth.json", `{"user": "app"}`)
+	write("parts/common.json", "{\n  \"limits\": {\"rps\": 10}\n}")
+
+	doc, err := ParseDocument(main, Options{ResolveRefs: true})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"db":     map[string]interface{}{"host": "a", "auth": map[string]interface{}{"user": "app"}},
+		"limits": map[string]interface{}{"rps": float64(10)},
+		"local":  map[string]interface{}{"$ref": "#/db"},
+		"remote": map[string]interface{}{"$ref": "https://example.com/schema.json"},
+	}, doc.Data)
+	assert.Equal(t, Position{File: main, Line: 2, Column: 1}, doc.Position([]string{"limits"}))
+	assert.Equal(t, Position{File: dir + "/parts/common.json", Line: 2, Column: 14},
+		doc.Position([]string{"limits", "rps"}))
+	assert.Equal(t, Position{File: dir + "/parts/secrets/auth.json", Line: 1, Column: 2},
+		doc.Position([]string{"db", "auth", "user"}))
+
+	unresolved, err := ParseFile(main)
+	require.NoError(t, err)
+	assert.Equal(t, TaggedValue{Tag: "!include", Value: "parts/db.yaml"}, unresolved["db"])
+
+	cyclic := write("a.yaml", "b: !include b.yaml\n")
+	write("b.yaml", "a: !include a.yaml\n")
+	_, err = ParseDocument(cyclic, Options{ResolveRefs: true})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "reference cycle: "+cyclic+" -> "+dir+"/b.yaml -> "+cyclic)
+
+	broken := write("broken.json", `{"x": {"$ref": "parts/common.json#/missing"}}`)
+	_, err = ParseDocument(broken, Options{ResolveRefs: true})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "/missing not found")
+
+	merged := write("merged.yaml", "server:\n  $ref: parts/common.json#/limits\n  burst: 5\n")
+	doc, err = ParseDocument(merged, Options{ResolveRefs: true})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"server": map[string]interface{}{"rps": float64(10), "burst": float64(5)},
+	}, doc.Data)
+	assert.Equal(t, Position{File: merged, Line: 3, Column: 3}, doc.Position([]string{"server", "burst"}))
+	assert.Equal(t, Position{File: dir + "/parts/common.json", Line: 2, Column: 14},
+		doc.Position([]string{"server", "rps"}))
+
+	scalar := write("scalar.json", `{"x": {"$ref": "parts/common.json#/limits/rps", "burst": 5}}`)
+	_, err = ParseDocument(scalar, Options{ResolveRefs: true})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "keys next to $ref need an object")
+
+	self := write("self.yaml", "defs:\n  x: 1\nvalue:\n  $ref: self.yaml#/defs\n")
+	doc, err = ParseDocument(self, Options{ResolveRefs: true})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"x": float64(1)}, doc.Data.(map[string]interface{})["value"])
+
+	loop := write("loop.yaml", "x:\n  $ref: loop.yaml#/x\n")
+	_, err = ParseDocument(loop, Options{ResolveRefs: true})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "reference cycle: "+loop+"#/x -> "+loop+"#/x")
+}
@@ -0,0 +1,93 @@
+package parsing
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// unitExtensions lists the systemd unit types parsed as INI files
+var unitExtensions = map[string]bool{
+	".service": true, ".socket": true, ".timer": true, ".mount": true,
+	".automount": true, ".swap": true, ".target": true, ".path": true,
+	".slice": true, ".scope": true, ".device": true,
+	".network": true, ".netdev": true, ".link": true,
+}
+
+// parseINI parses INI style files and systemd units. Sections become nested
+// maps and keys outside of any section stay at the top level. Keys repeated
+// within a section are collected into an array in file order. Lines starting
+// with ";" or "#" are comments and a trailing backslash continues the value
+// on the next line. All values are strings.
+func parseINI(iniData []byte) (map[string]interface{}, error) {
+	result := map[string]interface{}{}
+	section := result
+
+	scanner := bufio.NewScanner(bytes.NewReader(iniData))
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		start := lineNo
+		for strings.HasSuffix(line, `\`) && scanner.Scan() {
+			lineNo++
+			line = strings.TrimSpace(strings.TrimSuffix(line, `\`)) + " " + strings.TrimSpace(scanner.Text())
+		}
+
+		switch {
+		case line == "" || line[0] == ';' || line[0] == '#':
+			continue
+		case line[0] == '[':
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("failed to parse ini: line %d: unterminated section header", start)
+			}
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			existing, ok := result[name].(map[string]interface{})
+			if !ok {
+				if _, taken := result[name]; taken {
+					return nil, fmt.Errorf("failed to parse ini: line %d: section %q clashes with a key", start, name)
+				}
+				existing = map[string]interface{}{}
+				result[name] = existing
+			}
+			section = existing
+		default:
+			key, value := splitINILine(line)
+			if key == "" {
+				return nil, fmt.Errorf("failed to parse ini: line %d: missing key", start)
+			}
+			addINIValue(section, key, value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to parse ini: %w", err)
+	}
+
+	return result, nil
+}
+
+// splitINILine splits a "key = value" line. A colon is accepted as the
+// separator when the line has no equals sign; a bare key has an empty value.
+func splitINILine(line string) (string, string) {
+	sep := strings.IndexByte(line, '=')
+	if sep < 0 {
+		sep = strings.IndexByte(line, ':')
+	}
+	if sep < 0 {
+		return line, ""
+	}
+	return strings.TrimSpace(line[:sep]), strings.TrimSpace(line[sep+1:])
+}
+
+// addINIValue stores a value, turning repeated keys into an array
+func addINIValue(section map[string]interface{}, key, value string) {
+	switch existing := section[key].(type) {
+	case nil:
+		section[key] = value
+	case []interface{}:
+		section[key] = append(existing, value)
+	default:
+		section[key] = []interface{}{existing, value}
+	}
+}
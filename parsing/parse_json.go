@@ -0,0 +1,116 @@
+package parsing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// parseJSON parses a JSON document. Numbers are decoded from their text so
+// that integers a float64 cannot hold exactly are not rounded, see normalize.
+func parseJSON(jsonData []byte) (interface{}, error) {
+	var raw json.RawMessage
+	if err := json.Unmarshal(jsonData, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse json: %w", err)
+	}
+
+	var result interface{}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	if err := decoder.Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to parse json: %w", err)
+	}
+	return normalize(result), nil
+}
+
+// locateJSON records the positions of a JSON document
+func locateJSON(doc *Document, data []byte) error {
+	return (&jsonLocator{doc: doc, data: data, lines: newSourceLines(data)}).locate()
+}
+
+// locateLenientJSON records the positions of a JSONC or JSON5 document.
+// The rewritten strict JSON is walked and its offsets are mapped back to
+// the original source.
+func locateLenientJSON(doc *Document, data []byte) error {
+	r := &jsonRelaxer{src: data}
+	if err := r.relax(); err != nil {
+		return fmt.Errorf("failed to parse json: %w", err)
+	}
+	l := &jsonLocator{doc: doc, data: r.out.Bytes(), lines: newSourceLines(data), source: r.sourceOffset}
+	return l.locate()
+}
+
+// jsonLocator walks the tokens of a JSON document, recording the offset
+// each key and value starts at
+type jsonLocator struct {
+	doc   *Document
+	data  []byte
+	lines *sourceLines
+	// source maps an offset of data to the original source, if they differ
+	source  func(offset int) int
+	decoder *json.Decoder
+}
+
+func (l *jsonLocator) locate() error {
+	l.decoder = json.NewDecoder(bytes.NewReader(l.data))
+	if err := l.value(nil); err != nil {
+		return fmt.Errorf("failed to parse json: %w", err)
+	}
+	return nil
+}
+
+// value records the value starting at the next token and everything inside it
+func (l *jsonLocator) value(path []string) error {
+	pos := l.next()
+	token, err := l.decoder.Token()
+	if err != nil {
+		return err
+	}
+	l.doc.Values[Pointer(path)] = pos
+
+	switch token {
+	case json.Delim('{'):
+		keys := keySet{}
+		for l.decoder.More() {
+			keyPos := l.next()
+			token, err := l.decoder.Token()
+			if err != nil {
+				return err
+			}
+			key, _ := token.(string)
+			keyPath := append(path[:len(path):len(path)], key)
+			keys.add(l.doc, keyPath, keyPos)
+			l.doc.Keys[Pointer(keyPath)] = keyPos
+			if err := l.value(keyPath); err != nil {
+				return err
+			}
+		}
+		_, err = l.decoder.Token()
+	case json.Delim('['):
+		for i := 0; l.decoder.More(); i++ {
+			if err := l.value(append(path[:len(path):len(path)], strconv.Itoa(i))); err != nil {
+				return err
+			}
+		}
+		_, err = l.decoder.Token()
+	}
+	return err
+}
+
+// next returns the position of the next token, skipping the whitespace and
+// separators the decoder has not consumed yet
+func (l *jsonLocator) next() Position {
+	offset := int(l.decoder.InputOffset())
+	for offset < len(l.data) && isJSONSeparator(l.data[offset]) {
+		offset++
+	}
+	if l.source != nil {
+		offset = l.source(offset)
+	}
+	return l.lines.position(offset)
+}
+
+func isJSONSeparator(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == ',' || c == ':'
+}
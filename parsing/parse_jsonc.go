@@ -0,0 +1,264 @@
+package parsing
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// parseLenientJSON parses JSONC and JSON5 documents by rewriting them into
+// strict JSON first. Comments, trailing commas, single-quoted strings,
+// unquoted keys, hexadecimal numbers, explicit plus signs, leading or
+// trailing decimal points and escaped line breaks in strings are accepted.
+func parseLenientJSON(jsonData []byte) (interface{}, error) {
+	strict, err := relaxJSON(jsonData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse json: %w", err)
+	}
+	return parseJSON(strict)
+}
+
+// relaxJSON rewrites a lenient JSON document into strict JSON
+func relaxJSON(src []byte) ([]byte, error) {
+	r := &jsonRelaxer{src: src}
+	if err := r.relax(); err != nil {
+		return nil, err
+	}
+	return r.out.Bytes(), nil
+}
+
+// jsonRelaxer holds the state of one relaxJSON run
+type jsonRelaxer struct {
+	src []byte
+	pos int
+	out bytes.Buffer
+	// marks pairs the output offset of every translated token with its
+	// offset in src, in increasing order
+	marks [][2]int
+}
+
+// relax translates the whole source into out
+func (r *jsonRelaxer) relax() error {
+	for r.pos < len(r.src) {
+		r.marks = append(r.marks, [2]int{r.out.Len(), r.pos})
+		if err := r.next(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sourceOffset maps an offset of the output back to the source
+func (r *jsonRelaxer) sourceOffset(offset int) int {
+	i := sort.Search(len(r.marks), func(i int) bool { return r.marks[i][0] > offset }) - 1
+	if i < 0 {
+		return offset
+	}
+	return r.marks[i][1] + min(offset-r.marks[i][0], len(r.src)-r.marks[i][1])
+}
+
+// next translates the token starting at the current position
+func (r *jsonRelaxer) next() error {
+	c := r.src[r.pos]
+	switch {
+	case c == '/' && r.pos+1 < len(r.src) && (r.src[r.pos+1] == '/' || r.src[r.pos+1] == '*'):
+		return r.skipComment()
+	case c == '"' || c == '\'':
+		return r.copyString(c)
+	case c == ',':
+		r.pos++
+		if !r.closesNext() {
+			r.out.WriteByte(',')
+		}
+		return nil
+	case isJSONIdentStart(c):
+		return r.copyIdentifier()
+	case c == '+' || c == '-' || c == '.' || (c >= '0' && c <= '9'):
+		return r.copyNumber()
+	default:
+		r.out.WriteByte(c)
+		r.pos++
+		return nil
+	}
+}
+
+// skipComment skips a line or block comment
+func (r *jsonRelaxer) skipComment() error {
+	if r.src[r.pos+1] == '/' {
+		end := bytes.IndexByte(r.src[r.pos:], '\n')
+		if end < 0 {
+			r.pos = len(r.src)
+		} else {
+			r.pos += end
+		}
+		return nil
+	}
+
+	end := bytes.Index(r.src[r.pos+2:], []byte("*/"))
+	if end < 0 {
+		return fmt.Errorf("unterminated block comment at offset %d", r.pos)
+	}
+	r.pos += end + 4
+	r.out.WriteByte(' ')
+	return nil
+}
+
+// closesNext reports whether only whitespace and comments separate the
+// current position from a closing bracket, making a comma a trailing one
+func (r *jsonRelaxer) closesNext() bool {
+	for i := r.pos; i < len(r.src); i++ {
+		switch c := r.src[i]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+		case c == '/' && i+1 < len(r.src) && r.src[i+1] == '/':
+			end := bytes.IndexByte(r.src[i:], '\n')
+			if end < 0 {
+				return false
+			}
+			i += end
+		case c == '/' && i+1 < len(r.src) && r.src[i+1] == '*':
+			end := bytes.Index(r.src[i+2:], []byte("*/"))
+			if end < 0 {
+				return false
+			}
+			i += end + 3
+		default:
+			return c == '}' || c == ']'
+		}
+	}
+	return false
+}
+
+// copyString writes a single- or double-quoted string as a JSON string
+func (r *jsonRelaxer) copyString(quote byte) error {
+	start := r.pos
+	r.pos++
+	r.out.WriteByte('"')
+	for r.pos < len(r.src) {
+		c := r.src[r.pos]
+		r.pos++
+		switch {
+		case c == quote:
+			r.out.WriteByte('"')
+			return nil
+		case c == '"':
+			r.out.WriteString(`\"`)
+		case c == '\n':
+			return fmt.Errorf("unterminated string at offset %d", start)
+		case c == '\\' && r.pos < len(r.src):
+			if err := r.copyEscape(); err != nil {
+				return err
+			}
+		default:
+			r.out.WriteByte(c)
+		}
+	}
+	return fmt.Errorf("unterminated string at offset %d", start)
+}
+
+// copyEscape translates the escape sequence after a backslash
+func (r *jsonRelaxer) copyEscape() error {
+	c := r.src[r.pos]
+	r.pos++
+	switch c {
+	case '\'':
+		r.out.WriteByte('\'')
+	case '\n':
+		// escaped line break continues the string
+	case '\r':
+		if r.pos < len(r.src) && r.src[r.pos] == '\n' {
+			r.pos++
+		}
+	case 'x':
+		if r.pos+2 > len(r.src) {
+			return fmt.Errorf("malformed \\x escape at offset %d", r.pos)
+		}
+		code, err := strconv.ParseUint(string(r.src[r.pos:r.pos+2]), 16, 8)
+		if err != nil {
+			return fmt.Errorf("malformed \\x escape at offset %d", r.pos)
+		}
+		r.out.WriteString(fmt.Sprintf(`\u%04x`, code))
+		r.pos += 2
+	case '0':
+		r.out.WriteString(`\u0000`)
+	case 'v':
+		r.out.WriteString(`\u000b`)
+	case '"', '\\', '/', 'b', 'f', 'n', 'r', 't', 'u':
+		r.out.WriteByte('\\')
+		r.out.WriteByte(c)
+	default:
+		r.out.WriteByte(c)
+	}
+	return nil
+}
+
+func isJSONIdentStart(c byte) bool {
+	return c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isJSONIdentPart(c byte) bool {
+	return isJSONIdentStart(c) || (c >= '0' && c <= '9')
+}
+
+// copyIdentifier writes literals as they are and quotes unquoted keys
+func (r *jsonRelaxer) copyIdentifier() error {
+	start := r.pos
+	for r.pos < len(r.src) && isJSONIdentPart(r.src[r.pos]) {
+		r.pos++
+	}
+	ident := string(r.src[start:r.pos])
+	switch ident {
+	case "true", "false", "null":
+		r.out.WriteString(ident)
+	case "Infinity", "NaN":
+		return fmt.Errorf("%s at offset %d cannot be represented", ident, start)
+	default:
+		r.out.WriteString(strconv.Quote(ident))
+	}
+	return nil
+}
+
+// copyNumber writes a number in strict JSON notation
+func (r *jsonRelaxer) copyNumber() error {
+	start := r.pos
+	for r.pos < len(r.src) && strings.IndexByte("+-.0123456789abcdefABCDEFxX", r.src[r.pos]) >= 0 {
+		if r.pos > start && (r.src[r.pos] == '+' || r.src[r.pos] == '-') &&
+			r.src[r.pos-1] != 'e' && r.src[r.pos-1] != 'E' {
+			break
+		}
+		r.pos++
+	}
+	token := string(r.src[start:r.pos])
+
+	sign := ""
+	switch {
+	case strings.HasPrefix(token, "-"):
+		sign, token = "-", token[1:]
+	case strings.HasPrefix(token, "+"):
+		token = token[1:]
+	}
+	if token == "Infinity" || token == "" {
+		return fmt.Errorf("invalid number at offset %d", start)
+	}
+
+	if strings.HasPrefix(token, "0x") || strings.HasPrefix(token, "0X") {
+		value, err := strconv.ParseUint(token[2:], 16, 64)
+		if err != nil {
+			return fmt.Errorf("invalid hexadecimal number at offset %d", start)
+		}
+		r.out.WriteString(sign + strconv.FormatUint(value, 10))
+		return nil
+	}
+
+	if strings.HasPrefix(token, ".") {
+		token = "0" + token
+	}
+	token = strings.Replace(token, ".e", ".0e", 1)
+	token = strings.Replace(token, ".E", ".0E", 1)
+	if strings.HasSuffix(token, ".") {
+		token += "0"
+	}
+	r.out.WriteString(sign + token)
+	return nil
+}
@@ -0,0 +1,161 @@
+package parsing
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+)
+
+// parseProperties parses Java .properties files following java.util.Properties:
+// keys end at the first unescaped "=", ":" or whitespace, "#" and "!" start
+// comment lines, a trailing backslash continues the logical line and \uXXXX
+// escapes are decoded. With nest set, dotted keys become nested maps.
+func parseProperties(propertiesData []byte, nest bool) (map[string]interface{}, error) {
+	result := map[string]interface{}{}
+
+	lines := strings.Split(strings.ReplaceAll(string(propertiesData), "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		start := i + 1
+		line := strings.TrimLeft(lines[i], " \t\f")
+		if line == "" || line[0] == '#' || line[0] == '!' {
+			continue
+		}
+		for continuesLine(line) && i+1 < len(lines) {
+			i++
+			line = line[:len(line)-1] + strings.TrimLeft(lines[i], " \t\f")
+		}
+		if continuesLine(line) {
+			line = line[:len(line)-1]
+		}
+
+		rawKey, rawValue := splitPropertiesLine(line)
+		key, err := unescapeProperties(rawKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse properties: line %d: %w", start, err)
+		}
+		value, err := unescapeProperties(rawValue)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse properties: line %d: %w", start, err)
+		}
+		result[key] = value
+	}
+
+	if nest {
+		return nestDottedKeys(result)
+	}
+	return result, nil
+}
+
+// continuesLine reports whether the line ends with an odd number of backslashes
+func continuesLine(line string) bool {
+	count := 0
+	for i := len(line) - 1; i >= 0 && line[i] == '\\'; i-- {
+		count++
+	}
+	return count%2 == 1
+}
+
+// splitPropertiesLine splits a logical line into its raw key and raw value
+func splitPropertiesLine(line string) (string, string) {
+	end := 0
+	for end < len(line) {
+		c := line[end]
+		if c == '\\' {
+			end += 2
+			continue
+		}
+		if c == '=' || c == ':' || c == ' ' || c == '\t' || c == '\f' {
+			break
+		}
+		end++
+	}
+	if end > len(line) {
+		end = len(line)
+	}
+
+	rest := strings.TrimLeft(line[end:], " \t\f")
+	if rest != "" && (rest[0] == '=' || rest[0] == ':') {
+		rest = strings.TrimLeft(rest[1:], " \t\f")
+	}
+	return line[:end], rest
+}
+
+// unescapeProperties resolves backslash escapes of a key or value
+func unescapeProperties(s string) (string, error) {
+	if !strings.Contains(s, `\`) {
+		return s, nil
+	}
+
+	var result strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			result.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 't':
+			result.WriteByte('\t')
+		case 'n':
+			result.WriteByte('\n')
+		case 'r':
+			result.WriteByte('\r')
+		case 'f':
+			result.WriteByte('\f')
+		case 'u':
+			if i+5 > len(s) {
+				return "", fmt.Errorf("malformed \\uXXXX escape")
+			}
+			code, err := strconv.ParseUint(s[i+1:i+5], 16, 16)
+			if err != nil {
+				return "", fmt.Errorf("malformed \\uXXXX escape: %s", s[i-1:i+5])
+			}
+			r := rune(code)
+			i += 4
+			// characters outside the BMP are escaped as a UTF-16 surrogate pair
+			if utf16.IsSurrogate(r) && strings.HasPrefix(s[i+1:], `\u`) && i+7 <= len(s) {
+				if low, err := strconv.ParseUint(s[i+3:i+7], 16, 16); err == nil {
+					if pair := utf16.DecodeRune(r, rune(low)); pair != unicode.ReplacementChar {
+						r = pair
+						i += 6
+					}
+				}
+			}
+			result.WriteRune(r)
+		default:
+			result.WriteByte(s[i])
+		}
+	}
+	return result.String(), nil
+}
+
+// nestDottedKeys turns keys such as "db.pool.size" into nested maps
+func nestDottedKeys(flat map[string]interface{}) (map[string]interface{}, error) {
+	result := map[string]interface{}{}
+	for key, value := range flat {
+		parts := strings.Split(key, ".")
+		node := result
+		for i, part := range parts[:len(parts)-1] {
+			child, exists := node[part]
+			if !exists {
+				child = map[string]interface{}{}
+				node[part] = child
+			}
+			childMap, ok := child.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("failed to nest properties: %q is both a value and a parent of %q",
+					strings.Join(parts[:i+1], "."), key)
+			}
+			node = childMap
+		}
+
+		last := parts[len(parts)-1]
+		if _, exists := node[last]; exists {
+			return nil, fmt.Errorf("failed to nest properties: %q is both a value and a parent of other keys", key)
+		}
+		node[last] = value
+	}
+	return result, nil
+}
@@ -0,0 +1,16 @@
+package parsing
+
+import (
+	"fmt"
+
+	"github.com/BurntSushi/toml"
+)
+
+func parseTOML(tomlData []byte) (map[string]interface{}, error) {
+	var result map[string]interface{}
+	if _, err := toml.Decode(string(tomlData), &result); err != nil {
+		return nil, fmt.Errorf("failed to parse toml: %w", err)
+	}
+
+	return normalize(result).(map[string]interface{}), nil
+}
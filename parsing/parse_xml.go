@@ -0,0 +1,163 @@
+package parsing
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Reserved keys used when converting XML elements
+const (
+	xmlAttrPrefix = "@"
+	xmlTextKey    = "#text"
+)
+
+// xmlNamespace is the namespace bound to the reserved xml prefix
+const xmlNamespace = "http://www.w3.org/XML/1998/namespace"
+
+// parseXML converts an XML document into nested maps using these rules:
+//   - the result has a single key, the name of the root element;
+//   - an element without attributes and child elements becomes its text;
+//   - any other element becomes a map in which attributes are stored under
+//     "@" + name, child elements under their names and text under "#text";
+//   - sibling elements with the same name are collected into an array in
+//     document order;
+//   - text is trimmed and separate text segments of one element are joined
+//     with a single space; comments and processing instructions are dropped;
+//   - names in the default namespace are used as is, names in a prefixed
+//     namespace are written as "prefix:name" with the prefix declared in the
+//     document, and xmlns declarations are kept as attributes.
+//
+// All values are strings.
+func parseXML(xmlData []byte) (map[string]interface{}, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(xmlData))
+	root := &xmlElement{}
+	stack := []*xmlElement{root}
+
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse xml: %w", err)
+		}
+
+		current := stack[len(stack)-1]
+		switch t := token.(type) {
+		case xml.StartElement:
+			element := newXMLElement(t, current)
+			current.addChild(element)
+			stack = append(stack, element)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if text := strings.TrimSpace(string(t)); text != "" {
+				current.text = append(current.text, text)
+			}
+		}
+	}
+
+	if len(root.children) != 1 {
+		return nil, fmt.Errorf("failed to parse xml: expected a single root element")
+	}
+	if len(root.text) > 0 {
+		return nil, fmt.Errorf("failed to parse xml: text outside of the root element")
+	}
+	return root.value().(map[string]interface{}), nil
+}
+
+// xmlElement collects the parts of an element while the document is read
+type xmlElement struct {
+	name       string
+	attrs      map[string]interface{}
+	children   []*xmlElement
+	text       []string
+	namespaces map[string]string // namespace URI to prefix, including inherited ones
+	defaultNS  string
+}
+
+func newXMLElement(start xml.StartElement, parent *xmlElement) *xmlElement {
+	element := &xmlElement{
+		attrs:      map[string]interface{}{},
+		namespaces: map[string]string{xmlNamespace: "xml"},
+		defaultNS:  parent.defaultNS,
+	}
+	for uri, prefix := range parent.namespaces {
+		element.namespaces[uri] = prefix
+	}
+
+	for _, attr := range start.Attr {
+		switch {
+		case attr.Name.Space == "xmlns":
+			element.namespaces[attr.Value] = attr.Name.Local
+			element.attrs[xmlAttrPrefix+"xmlns:"+attr.Name.Local] = attr.Value
+		case attr.Name.Space == "" && attr.Name.Local == "xmlns":
+			element.defaultNS = attr.Value
+			element.attrs[xmlAttrPrefix+"xmlns"] = attr.Value
+		}
+	}
+	for _, attr := range start.Attr {
+		if attr.Name.Space == "xmlns" || (attr.Name.Space == "" && attr.Name.Local == "xmlns") {
+			continue
+		}
+		element.attrs[xmlAttrPrefix+element.qualify(attr.Name, false)] = attr.Value
+	}
+	element.name = element.qualify(start.Name, true)
+	return element
+}
+
+// qualify writes a name with the prefix declared for its namespace. Unprefixed
+// attributes and elements in the default namespace keep their local name.
+func (e *xmlElement) qualify(name xml.Name, isElement bool) string {
+	if name.Space == "" || (isElement && name.Space == e.defaultNS) {
+		return name.Local
+	}
+	if prefix, ok := e.namespaces[name.Space]; ok {
+		return prefix + ":" + name.Local
+	}
+	return name.Space + ":" + name.Local
+}
+
+func (e *xmlElement) addChild(child *xmlElement) {
+	e.children = append(e.children, child)
+}
+
+// value converts the element content according to the rules of parseXML
+func (e *xmlElement) value() interface{} {
+	if len(e.attrs) == 0 && len(e.children) == 0 {
+		return strings.Join(e.text, " ")
+	}
+
+	result := make(map[string]interface{}, len(e.attrs)+len(e.children)+1)
+	for k, v := range e.attrs {
+		result[k] = v
+	}
+	for _, child := range e.children {
+		value := child.value()
+		switch existing := result[child.name].(type) {
+		case nil:
+			result[child.name] = value
+		case xmlRepeated:
+			result[child.name] = append(existing, value)
+		default:
+			result[child.name] = xmlRepeated{existing, value}
+		}
+	}
+	if len(e.text) > 0 {
+		result[xmlTextKey] = strings.Join(e.text, " ")
+	}
+
+	for k, v := range result {
+		if repeated, ok := v.(xmlRepeated); ok {
+			result[k] = []interface{}(repeated)
+		}
+	}
+	return result
+}
+
+// xmlRepeated marks arrays built from repeated elements while converting
+type xmlRepeated []interface{}
@@ -0,0 +1,453 @@
+package parsing
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultIdentity identifies Kubernetes resources in multi-document streams
+var DefaultIdentity = []string{"apiVersion", "kind", "metadata.namespace", "metadata.name"}
+
+// TaggedValue keeps the custom tag of a YAML node, such as !secret or
+// !include, next to its value so that a change of tag is a change of value
+type TaggedValue struct {
+	Tag   string
+	Value interface{}
+}
+
+// String renders the value the way it is written in YAML
+func (v TaggedValue) String() string {
+	return fmt.Sprintf("%s %v", v.Tag, v.Value)
+}
+
+// MarshalJSON encodes the untagged value, JSON having no notion of tags
+func (v TaggedValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(v.Value)
+}
+
+// parseYAML parses a YAML stream. A single document is returned as is,
+// whether its root is a mapping, a sequence or a scalar.
+// Several documents, or any stream when opts.MultiDocument is set, are
+// returned as a map from the identity of each document to its content, so
+// that documents are paired by identity rather than by position.
+//
+// Anchors and aliases are resolved, merge keys (<<) are expanded with
+// explicit keys taking precedence, keys that are not strings are turned
+// into their canonical string form and custom tags are kept as TaggedValue.
+func parseYAML(yamlData []byte, opts Options) (interface{}, error) {
+	documents, err := decodeYAML(yamlData)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := documentKeys(documents, opts)
+	if keys == nil {
+		if len(documents) == 0 {
+			return nil, nil
+		}
+		return documents[0].value, nil
+	}
+	result := make(map[string]interface{}, len(documents))
+	for i, document := range documents {
+		result[keys[i]] = document.value
+	}
+	return result, nil
+}
+
+// yamlDocument is one non-empty document of a YAML stream
+type yamlDocument struct {
+	node  *yaml.Node
+	value interface{}
+}
+
+// decodeYAML decodes and converts the documents of a YAML stream
+func decodeYAML(yamlData []byte) ([]yamlDocument, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(yamlData))
+	var documents []yamlDocument
+	for {
+		var node yaml.Node
+		err := decoder.Decode(&node)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse yaml: %w", err)
+		}
+
+		value, err := (&yamlConverter{}).convert(&node)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse yaml: %w", err)
+		}
+		if value == nil {
+			continue
+		}
+		documents = append(documents, yamlDocument{node: &node, value: value})
+	}
+	return documents, nil
+}
+
+// documentKeys returns the key of every document of a stream keyed by
+// identity, or nil when the stream is returned as a single document
+func documentKeys(documents []yamlDocument, opts Options) []string {
+	if !opts.MultiDocument && len(documents) <= 1 {
+		return nil
+	}
+
+	identity := opts.Identity
+	if len(identity) == 0 {
+		identity = DefaultIdentity
+	}
+	keys := make([]string, 0, len(documents))
+	taken := make(map[string]bool, len(documents))
+	for i, document := range documents {
+		base := documentIdentity(document.value, identity)
+		if base == "" {
+			base = fmt.Sprintf("document %d", i+1)
+		}
+		key := base
+		for n := 2; taken[key]; n++ {
+			key = fmt.Sprintf("%s (%d)", base, n)
+		}
+		taken[key] = true
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// locateYAML records the positions of the documents of a YAML stream.
+// Values reached through an alias or a merge key are not recorded and
+// report the position of the node they are used in.
+func locateYAML(doc *Document, yamlData []byte, opts Options) error {
+	documents, err := decodeYAML(yamlData)
+	if err != nil {
+		return err
+	}
+
+	keys := documentKeys(documents, opts)
+	doc.Keyed = keys != nil
+	for i, document := range documents {
+		var path []string
+		if keys != nil {
+			path = []string{keys[i]}
+		}
+		if err := locateYAMLNode(doc, document.node.Content[0], path); err != nil {
+			return fmt.Errorf("failed to parse yaml: %w", err)
+		}
+		locatePlainScalars(doc, document.node.Content[0], path)
+	}
+	return nil
+}
+
+// locateYAMLNode records the position of a node and of the keys and values inside it
+func locateYAMLNode(doc *Document, node *yaml.Node, path []string) error {
+	doc.Values[Pointer(path)] = yamlPosition(node)
+	switch node.Kind {
+	case yaml.MappingNode:
+		keys := keySet{}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			keyNode, valueNode := node.Content[i], node.Content[i+1]
+			if keyNode.Tag == "!!merge" {
+				continue
+			}
+			key, err := (&yamlConverter{}).key(keyNode)
+			if err != nil {
+				return err
+			}
+			keyPath := append(path[:len(path):len(path)], key)
+			keys.add(doc, keyPath, yamlPosition(keyNode))
+			doc.Keys[Pointer(keyPath)] = yamlPosition(keyNode)
+			if err := locateYAMLNode(doc, valueNode, keyPath); err != nil {
+				return err
+			}
+		}
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			if err := locateYAMLNode(doc, item, append(path[:len(path):len(path)], strconv.Itoa(i))); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// locatePlainScalars records the pointers of the strings written as plain
+// scalars, including those reached through an alias or a merge key
+func locatePlainScalars(doc *Document, node *yaml.Node, path []string) {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		if node.Style == 0 && node.Tag == "!!str" {
+			if doc.plain == nil {
+				doc.plain = map[string]bool{}
+			}
+			doc.plain[Pointer(path)] = true
+		}
+	case yaml.AliasNode:
+		locatePlainScalars(doc, node.Alias, path)
+	case yaml.MappingNode:
+		for key, valueNode := range mappingValues(node) {
+			locatePlainScalars(doc, valueNode, append(path[:len(path):len(path)], key))
+		}
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			locatePlainScalars(doc, item, append(path[:len(path):len(path)], strconv.Itoa(i)))
+		}
+	}
+}
+
+// mappingValues returns the value node of every key of a mapping node,
+// merge keys expanded the way yamlConverter.mapping does
+func mappingValues(node *yaml.Node) map[string]*yaml.Node {
+	for node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	values := map[string]*yaml.Node{}
+	var sources []*yaml.Node
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keyNode, valueNode := node.Content[i], node.Content[i+1]
+		if keyNode.Tag == "!!merge" {
+			if valueNode.Kind == yaml.SequenceNode {
+				sources = append(sources, valueNode.Content...)
+			} else {
+				sources = append(sources, valueNode)
+			}
+			continue
+		}
+		if key, err := (&yamlConverter{}).key(keyNode); err == nil {
+			values[key] = valueNode
+		}
+	}
+	for _, source := range sources {
+		for key, valueNode := range mappingValues(source) {
+			if _, exists := values[key]; !exists {
+				values[key] = valueNode
+			}
+		}
+	}
+	return values
+}
+
+// resolvePlainScalar returns the value text has when written as a plain scalar
+func resolvePlainScalar(text string) (interface{}, error) {
+	return (&yamlConverter{}).scalar(&yaml.Node{Kind: yaml.ScalarNode, Value: text})
+}
+
+// yamlPosition returns the position of a node in its file
+func yamlPosition(node *yaml.Node) Position {
+	return Position{Line: node.Line, Column: node.Column}
+}
+
+// KeyByIdentity turns a document that is not keyed by identity into a map
+// holding it under its identity, as if it were a stream of one document
+// parsed with Options.MultiDocument. This pairs a single document with the
+// documents of a stream. Keyed documents are left unchanged.
+func (d *Document) KeyByIdentity(identity []string) {
+	if d.Keyed {
+		return
+	}
+	keyed := newDocument(map[string]interface{}{})
+	keyed.Keyed = true
+	if d.Data != nil {
+		keys := documentKeys([]yamlDocument{{value: d.Data}}, Options{MultiDocument: true, Identity: identity})
+		keyed.Data.(map[string]interface{})[keys[0]] = d.Data
+		d.nest(keys[0], keyed)
+	}
+	*d = *keyed
+}
+
+// documentIdentity joins the non-empty values found at the identity paths with "/"
+func documentIdentity(document interface{}, identity []string) string {
+	parts := make([]string, 0, len(identity))
+	for _, dotted := range identity {
+		value := document
+		for _, key := range strings.Split(dotted, ".") {
+			m, ok := value.(map[string]interface{})
+			if !ok {
+				value = nil
+				break
+			}
+			value = m[key]
+		}
+		if value != nil && value != "" {
+			parts = append(parts, fmt.Sprintf("%v", value))
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// yamlConverter turns yaml.Node trees into plain Go values. Anchored nodes
+// are converted once and shared by all of their aliases.
+type yamlConverter struct {
+	anchors   map[*yaml.Node]interface{}
+	expanding map[*yaml.Node]bool
+}
+
+func (c *yamlConverter) convert(node *yaml.Node) (interface{}, error) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			return nil, nil
+		}
+		return c.convert(node.Content[0])
+	case yaml.AliasNode:
+		return c.alias(node)
+	case yaml.MappingNode:
+		result, err := c.mapping(node)
+		if err != nil {
+			return nil, err
+		}
+		return tagged(node, result), nil
+	case yaml.SequenceNode:
+		result := make([]interface{}, 0, len(node.Content))
+		for _, item := range node.Content {
+			value, err := c.convert(item)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, value)
+		}
+		return tagged(node, result), nil
+	default:
+		return c.scalar(node)
+	}
+}
+
+// alias converts the node an alias points to, rejecting recursive aliases
+func (c *yamlConverter) alias(node *yaml.Node) (interface{}, error) {
+	if value, ok := c.anchors[node.Alias]; ok {
+		return value, nil
+	}
+	if c.expanding[node.Alias] {
+		return nil, fmt.Errorf("line %d: alias *%s is recursive", node.Line, node.Value)
+	}
+	if c.anchors == nil {
+		c.anchors = map[*yaml.Node]interface{}{}
+		c.expanding = map[*yaml.Node]bool{}
+	}
+
+	c.expanding[node.Alias] = true
+	value, err := c.convert(node.Alias)
+	delete(c.expanding, node.Alias)
+	if err != nil {
+		return nil, err
+	}
+	c.anchors[node.Alias] = value
+	return value, nil
+}
+
+// mapping converts a mapping node, expanding merge keys
+func (c *yamlConverter) mapping(node *yaml.Node) (map[string]interface{}, error) {
+	result := make(map[string]interface{}, len(node.Content)/2)
+	var merged []map[string]interface{}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keyNode, valueNode := node.Content[i], node.Content[i+1]
+		if keyNode.Tag == "!!merge" {
+			sources, err := c.mergeSources(valueNode)
+			if err != nil {
+				return nil, err
+			}
+			merged = append(merged, sources...)
+			continue
+		}
+
+		key, err := c.key(keyNode)
+		if err != nil {
+			return nil, err
+		}
+		value, err := c.convert(valueNode)
+		if err != nil {
+			return nil, err
+		}
+		result[key] = value
+	}
+
+	for _, source := range merged {
+		for k, v := range source {
+			if _, exists := result[k]; !exists {
+				result[k] = v
+			}
+		}
+	}
+	return result, nil
+}
+
+// mergeSources returns the mappings referenced by a merge key in priority order
+func (c *yamlConverter) mergeSources(node *yaml.Node) ([]map[string]interface{}, error) {
+	nodes := []*yaml.Node{node}
+	if node.Kind == yaml.SequenceNode {
+		nodes = node.Content
+	}
+
+	sources := make([]map[string]interface{}, 0, len(nodes))
+	for _, item := range nodes {
+		value, err := c.convert(item)
+		if err != nil {
+			return nil, err
+		}
+		source, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("line %d: merge key must reference a mapping", item.Line)
+		}
+		sources = append(sources, source)
+	}
+	return sources, nil
+}
+
+// key converts a mapping key into its canonical string form
+func (c *yamlConverter) key(node *yaml.Node) (string, error) {
+	value, err := c.convert(node)
+	if err != nil {
+		return "", err
+	}
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case nil:
+		return "null", nil
+	case float64:
+		return FormatNumber(v), nil
+	case map[string]interface{}, []interface{}, TaggedValue:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return "", fmt.Errorf("line %d: unsupported key: %w", node.Line, err)
+		}
+		return string(encoded), nil
+	default:
+		return fmt.Sprintf("%v", v), nil
+	}
+}
+
+// scalar decodes a scalar node according to its resolved tag
+func (c *yamlConverter) scalar(node *yaml.Node) (interface{}, error) {
+	plain := *node
+	if isCustomTag(node.Tag) {
+		plain.Tag = ""
+		plain.Style &^= yaml.TaggedStyle
+	}
+
+	var value interface{}
+	if err := plain.Decode(&value); err != nil {
+		return nil, err
+	}
+	return tagged(node, normalize(value)), nil
+}
+
+// tagged wraps a value in TaggedValue when its node carries a custom tag
+func tagged(node *yaml.Node, value interface{}) interface{} {
+	if !isCustomTag(node.Tag) {
+		return value
+	}
+	return TaggedValue{Tag: node.Tag, Value: value}
+}
+
+// isCustomTag reports whether a tag is neither a standard !! tag nor the non-specific "!"
+func isCustomTag(tag string) bool {
+	return strings.HasPrefix(tag, "!") && !strings.HasPrefix(tag, "!!") && tag != "!"
+}
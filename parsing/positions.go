@@ -0,0 +1,163 @@
+package parsing
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Position is a location in a source file. Lines and columns start at 1,
+// columns count bytes.
+type Position struct {
+	File   string
+	Line   int
+	Column int
+}
+
+// IsValid reports whether the position points into a file
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+// String formats the position as file:line:column
+func (p Position) String() string {
+	if !p.IsValid() {
+		return p.File
+	}
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+}
+
+// Document is a parsed file together with the positions of its keys and
+// values, indexed by the JSON pointer of the value (see Pointer). Formats
+// other than JSON and YAML do not record positions.
+type Document struct {
+	Data   interface{}
+	Keys   map[string]Position
+	Values map[string]Position
+	// Duplicates lists the keys defined more than once in the same object,
+	// the last definition being the one kept in Data
+	Duplicates []*DuplicateKey
+	// Keyed reports that Data maps the identity of each document of a YAML
+	// stream to its content, see Options.MultiDocument
+	Keyed bool
+	// plain holds the pointers of the strings written as plain YAML
+	// scalars, whose type depends on their text
+	plain map[string]bool
+}
+
+// DuplicateKey is a key defined again in an object that already has it
+type DuplicateKey struct {
+	Path  []string
+	First Position
+	Again Position
+}
+
+func (d *DuplicateKey) Error() string {
+	return fmt.Sprintf("%s: duplicate key %s, first defined at %s",
+		d.Again, strings.Join(d.Path, "."), d.First)
+}
+
+// newDocument creates a document with empty position tables
+func newDocument(data interface{}) *Document {
+	return &Document{Data: data, Keys: map[string]Position{}, Values: map[string]Position{}}
+}
+
+// Position returns the position of the key at path, or of the value when it
+// has no key. Values without a recorded position, such as those expanded
+// from a YAML alias, report the position of their closest ancestor.
+func (d *Document) Position(path []string) Position {
+	if d == nil {
+		return Position{}
+	}
+	for n := len(path); n >= 0; n-- {
+		pointer := Pointer(path[:n])
+		if pos, ok := d.Keys[pointer]; ok {
+			return pos
+		}
+		if pos, ok := d.Values[pointer]; ok {
+			return pos
+		}
+	}
+	return Position{}
+}
+
+// setFile fills in the file of every position that has none
+func (d *Document) setFile(file string) {
+	for _, positions := range []map[string]Position{d.Keys, d.Values} {
+		for pointer, pos := range positions {
+			positions[pointer] = pos.inFile(file)
+		}
+	}
+	for _, duplicate := range d.Duplicates {
+		duplicate.First = duplicate.First.inFile(file)
+		duplicate.Again = duplicate.Again.inFile(file)
+	}
+}
+
+// nest adds the positions and duplicates of d to target under the given key
+func (d *Document) nest(key string, target *Document) {
+	prefix := Pointer([]string{key})
+	for pointer, pos := range d.Keys {
+		target.Keys[prefix+pointer] = pos
+	}
+	for pointer, pos := range d.Values {
+		target.Values[prefix+pointer] = pos
+	}
+	for _, duplicate := range d.Duplicates {
+		duplicate.Path = append([]string{key}, duplicate.Path...)
+		target.Duplicates = append(target.Duplicates, duplicate)
+	}
+}
+
+// inFile returns the position with its file set, unless it already has one
+func (p Position) inFile(file string) Position {
+	if p.File == "" {
+		p.File = file
+	}
+	return p
+}
+
+// keySet tracks the keys of one object to find the duplicates among them
+type keySet map[string]Position
+
+// add records a key, adding it to the duplicates of doc if the object has it already
+func (s keySet) add(doc *Document, path []string, pos Position) {
+	if first, ok := s[path[len(path)-1]]; ok {
+		doc.Duplicates = append(doc.Duplicates, &DuplicateKey{Path: path, First: first, Again: pos})
+	}
+	s[path[len(path)-1]] = pos
+}
+
+// pointerEscaper escapes path segments as required by RFC 6901
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// Pointer joins path segments into a JSON pointer, "" being the root
+func Pointer(path []string) string {
+	var result strings.Builder
+	for _, segment := range path {
+		result.WriteByte('/')
+		result.WriteString(pointerEscaper.Replace(segment))
+	}
+	return result.String()
+}
+
+// sourceLines converts byte offsets of a source into line and column numbers
+type sourceLines struct {
+	starts []int
+}
+
+func newSourceLines(data []byte) *sourceLines {
+	starts := []int{0}
+	for i, c := range data {
+		if c == '\n' {
+			starts = append(starts, i+1)
+		}
+	}
+	return &sourceLines{starts: starts}
+}
+
+// position returns the line and column of a byte offset
+func (s *sourceLines) position(offset int) Position {
+	line := sort.Search(len(s.starts), func(i int) bool { return s.starts[i] > offset })
+	return Position{Line: line, Column: offset - s.starts[line-1] + 1}
+}
@@ -0,0 +1,237 @@
+package parsing
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// includeTag is the YAML tag whose value is the path of a file to include
+const includeTag = "!include"
+
+// refResolver replaces the references to other files found in a document
+type refResolver struct {
+	doc  *Document
+	file string
+	opts Options
+}
+
+// resolveRefs replaces every JSON reference object ({"$ref": "file#/pointer"})
+// and every !include tag of doc by the value it points to. Paths are read
+// relative to the directory of file and the references inside the referenced
+// values are resolved too. References within the document, such as
+// "#/definitions/x", and URLs are left as they are. The other keys of a
+// reference object are merged over the object it points to. The positions
+// of the included values point into the files they come from.
+func resolveRefs(doc *Document, file string, opts Options) error {
+	chain, err := opts.refChain.with(file, "")
+	if err != nil {
+		return err
+	}
+	opts.refChain = chain
+
+	r := &refResolver{doc: doc, file: file, opts: opts}
+	doc.Data, err = r.resolve(doc.Data, "")
+	return err
+}
+
+// resolve returns value with the references inside it replaced
+func (r *refResolver) resolve(value interface{}, pointer string) (interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if ref, ok := v["$ref"].(string); ok && isFileRef(ref) {
+			return r.resolveRefObject(v, ref, pointer)
+		}
+		for key, child := range v {
+			resolved, err := r.resolve(child, pointer+Pointer([]string{key}))
+			if err != nil {
+				return nil, err
+			}
+			v[key] = resolved
+		}
+	case []interface{}:
+		for i, child := range v {
+			resolved, err := r.resolve(child, pointer+Pointer([]string{strconv.Itoa(i)}))
+			if err != nil {
+				return nil, err
+			}
+			v[i] = resolved
+		}
+	case TaggedValue:
+		if ref, ok := v.Value.(string); ok && v.Tag == includeTag {
+			return r.include(ref, pointer)
+		}
+	}
+	return value, nil
+}
+
+// resolveRefObject returns the value a reference object points to, with the
+// other keys of the object merged over it. These keys keep their positions.
+func (r *refResolver) resolveRefObject(object map[string]interface{}, ref, pointer string) (interface{}, error) {
+	siblings := map[string]interface{}{}
+	saved := newDocument(nil)
+	for key, child := range object {
+		if key == "$ref" {
+			continue
+		}
+		keyPointer := pointer + Pointer([]string{key})
+		resolved, err := r.resolve(child, keyPointer)
+		if err != nil {
+			return nil, err
+		}
+		siblings[key] = resolved
+		movePositions(r.doc, saved, keyPointer)
+	}
+
+	value, err := r.include(ref, pointer)
+	if err != nil || len(siblings) == 0 {
+		return value, err
+	}
+	target, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s: %s: keys next to $ref need an object to merge into", displayName(r.file), ref)
+	}
+	merged := make(map[string]interface{}, len(target)+len(siblings))
+	for key, child := range target {
+		merged[key] = child
+	}
+	for key, child := range siblings {
+		merged[key] = child
+		movePositions(r.doc, newDocument(nil), pointer+Pointer([]string{key}))
+	}
+	movePositions(saved, r.doc, pointer)
+	return merged, nil
+}
+
+// movePositions moves the positions at pointer and below it from src to dst
+func movePositions(src, dst *Document, pointer string) {
+	for _, maps := range [][2]map[string]Position{{src.Keys, dst.Keys}, {src.Values, dst.Values}} {
+		for p, pos := range maps[0] {
+			if p == pointer || strings.HasPrefix(p, pointer+"/") {
+				maps[1][p] = pos
+				delete(maps[0], p)
+			}
+		}
+	}
+}
+
+// include parses the file a reference points to and returns the referenced
+// value with the references inside it resolved
+func (r *refResolver) include(ref, pointer string) (interface{}, error) {
+	file, fragment, _ := strings.Cut(ref, "#")
+	if !path.IsAbs(file) {
+		file = path.Join(path.Dir(r.file), file)
+	}
+
+	opts := r.opts
+	opts.Format = ""
+	opts.FormatHint = ""
+	opts.ResolveRefs = false
+	included, err := ParseDocument(file, opts)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s: %w", displayName(r.file), ref, err)
+	}
+	value, err := lookupPointer(included.Data, fragment)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s: %w", displayName(r.file), ref, err)
+	}
+
+	if opts.refChain, err = opts.refChain.with(file, fragment); err != nil {
+		return nil, fmt.Errorf("%s: %s: %w", displayName(r.file), ref, err)
+	}
+	nested := &refResolver{doc: included, file: file, opts: opts}
+	if value, err = nested.resolve(value, fragment); err != nil {
+		return nil, err
+	}
+	r.graft(pointer, included, fragment)
+	return value, nil
+}
+
+// graft replaces the positions under pointer by those of the included
+// document under its own pointer from. The key holding the reference keeps
+// its position in the including file.
+func (r *refResolver) graft(pointer string, included *Document, from string) {
+	for _, positions := range []map[string]Position{r.doc.Keys, r.doc.Values} {
+		for p := range positions {
+			if strings.HasPrefix(p, pointer+"/") {
+				delete(positions, p)
+			}
+		}
+	}
+	for p, pos := range included.Keys {
+		if strings.HasPrefix(p, from+"/") {
+			r.doc.Keys[pointer+p[len(from):]] = pos
+		}
+	}
+	for p, pos := range included.Values {
+		if p == from || strings.HasPrefix(p, from+"/") {
+			r.doc.Values[pointer+p[len(from):]] = pos
+		}
+	}
+}
+
+// isFileRef reports whether a reference points into another local file
+func isFileRef(ref string) bool {
+	return ref != "" && !strings.HasPrefix(ref, "#") && !strings.Contains(ref, "://")
+}
+
+// pointerUnescaper reverts pointerEscaper
+var pointerUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
+
+// lookupPointer returns the value a JSON pointer designates in value
+func lookupPointer(value interface{}, pointer string) (interface{}, error) {
+	if pointer == "" {
+		return value, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer %q", pointer)
+	}
+	for _, segment := range strings.Split(pointer[1:], "/") {
+		segment = pointerUnescaper.Replace(segment)
+		switch v := value.(type) {
+		case map[string]interface{}:
+			child, ok := v[segment]
+			if !ok {
+				return nil, fmt.Errorf("%s not found", pointer)
+			}
+			value = child
+		case []interface{}:
+			i, err := strconv.Atoi(segment)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, fmt.Errorf("%s not found", pointer)
+			}
+			value = v[i]
+		default:
+			return nil, fmt.Errorf("%s not found", pointer)
+		}
+	}
+	return value, nil
+}
+
+// refChain lists the values being resolved, from the outermost one, as
+// files followed by the fragment of the reference
+type refChain []string
+
+// with returns the chain extended by the value at fragment in file, or an
+// error when that value is already in it and resolving it again would never
+// end. Other values of a file being resolved can still be referenced.
+func (c refChain) with(file, fragment string) (refChain, error) {
+	key := file
+	if file != StdinPath {
+		if abs, err := filepath.Abs(file); err == nil {
+			key = abs
+		}
+	}
+	if fragment != "" {
+		key += "#" + fragment
+	}
+	for i, included := range c {
+		if included == key {
+			cycle := append(append([]string{}, c[i:]...), key)
+			return nil, fmt.Errorf("reference cycle: %s", strings.Join(cycle, " -> "))
+		}
+	}
+	return append(c[:len(c):len(c)], key), nil
+}
@@ -0,0 +1,64 @@
+package parsing
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+)
+
+// iniSectionHeader matches a line holding an INI section header
+var iniSectionHeader = regexp.MustCompile(`(?m)^[ \t]*\[[^\]\n]+\][ \t]*\r?$`)
+
+// sniffOrder lists the formats tried when the file name gives no hint, from
+// the most to the least strict syntax
+var sniffOrder = []string{"json", "xml", "toml", "yaml", "ini", "dotenv"}
+
+// parseSniffed recognises the format of data by trying opts.FormatHint and
+// then the parsers in sniffOrder, and returns the format and result of the
+// first one that accepts it. Only objects and arrays are accepted, any text
+// being a YAML scalar.
+func parseSniffed(data []byte, opts Options) (string, interface{}, error) {
+	trimmed := bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))
+	order := sniffOrder
+	if _, ok := parsers[opts.FormatHint]; ok {
+		order = append([]string{opts.FormatHint}, sniffOrder...)
+	}
+	for i, format := range order {
+		// The hinted format is tried on any text, an INI file compared with
+		// another INI file needing no section header for instance
+		hinted := i == 0 && format == opts.FormatHint && len(trimmed) > 0
+		if !hinted && !sniffCandidate(format, trimmed) {
+			continue
+		}
+		if result, err := parseValue(data, format, opts); err == nil && isCollection(result) {
+			return format, result, nil
+		}
+	}
+	return "", nil, fmt.Errorf("unable to detect the input format")
+}
+
+// sniffCandidate rules out formats whose parsers would accept almost any text
+func sniffCandidate(format string, trimmed []byte) bool {
+	switch format {
+	case "json":
+		return len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[')
+	case "xml":
+		return len(trimmed) > 0 && trimmed[0] == '<'
+	case "ini":
+		return iniSectionHeader.Match(trimmed)
+	default:
+		return len(trimmed) > 0
+	}
+}
+
+// isCollection reports whether a parsed root is a non-nil object or an array
+func isCollection(root interface{}) bool {
+	switch v := root.(type) {
+	case map[string]interface{}:
+		return v != nil
+	case []interface{}:
+		return true
+	default:
+		return false
+	}
+}
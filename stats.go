@@ -0,0 +1,129 @@
+package code
+
+import (
+	"io"
+	"strings"
+)
+
+// DiffStats summarises a diff in terms of leaf values
+type DiffStats struct {
+	Added     int // Leaves only present in file2
+	Removed   int // Leaves only present in file1
+	Changed   int // Leaves present in both files with different values
+	Unchanged int // Leaves present in both files with the same value
+	Sections  int // Top-level keys
+	Affected  int // Top-level keys containing at least one difference
+}
+
+// ComputeStats counts the leaves of a diff by status. Maps that were added,
+// removed or left unchanged as a whole contribute every leaf they contain.
+func ComputeStats(diff []DiffEntry) DiffStats {
+	var stats DiffStats
+	stats.Sections = len(diff)
+	for _, entry := range diff {
+		if entry.HasChanges() {
+			stats.Affected++
+		}
+		stats.addEntry(entry)
+	}
+	return stats
+}
+
+// Similarity returns the share of unchanged leaves as a percentage.
+// Two empty documents are considered identical.
+func (s DiffStats) Similarity() float64 {
+	total := s.Added + s.Removed + s.Changed + s.Unchanged
+	if total == 0 {
+		return 100
+	}
+	return float64(s.Unchanged) * 100 / float64(total)
+}
+
+func (s *DiffStats) addEntry(entry DiffEntry) {
+	switch entry.Status {
+	case StatusAdded:
+		s.Added += countLeaves(entry.NewVal)
+	case StatusRemoved:
+		s.Removed += countLeaves(entry.OldVal)
+	case StatusChanged:
+		s.Changed++
+	case StatusUnchanged:
+		s.Unchanged += countLeaves(entry.OldVal)
+	case StatusNested:
+		for _, child := range entry.Children {
+			s.addEntry(child)
+		}
+	}
+}
+
+// countLeaves returns the number of non-map values inside value.
+// An empty map counts as a single leaf.
+func countLeaves(value interface{}) int {
+	m, ok := value.(map[string]interface{})
+	if !ok || len(m) == 0 {
+		return 1
+	}
+	count := 0
+	for _, v := range m {
+		count += countLeaves(v)
+	}
+	return count
+}
+
+// writeStats writes the statistics one item per line, each line starting
+// with prefix
+func writeStats(w io.Writer, stats DiffStats, prefix string) error {
+	out := &errWriter{w: w}
+	out.printf("%sadded: %d\n", prefix, stats.Added)
+	out.printf("%sremoved: %d\n", prefix, stats.Removed)
+	out.printf("%schanged: %d\n", prefix, stats.Changed)
+	out.printf("%sunchanged: %d\n", prefix, stats.Unchanged)
+	out.printf("%ssections affected: %d of %d\n", prefix, stats.Affected, stats.Sections)
+	out.printf("%ssimilarity: %.1f%%", prefix, stats.Similarity())
+	return out.err
+}
+
+// summaryComments maps the formats whose output is code to the comment
+// prefix the statistics are written with, so that the output stays valid
+var summaryComments = map[string]string{
+	"sql": "-- ",
+	"jq":  "# ",
+	"dot": "// ",
+}
+
+// summaryFormatter appends diff statistics to the output of another formatter
+type summaryFormatter struct {
+	inner   Formatter
+	comment string
+}
+
+func (f *summaryFormatter) Format(diff []DiffEntry) string {
+	var result strings.Builder
+	_ = f.FormatTo(&result, diff)
+	return result.String()
+}
+
+func (f *summaryFormatter) FormatTo(w io.Writer, diff []DiffEntry) error {
+	out := &countingWriter{w: w}
+	if err := WriteDiff(out, f.inner, diff); err != nil {
+		return err
+	}
+	if out.n > 0 {
+		if _, err := io.WriteString(w, "\n\n"); err != nil {
+			return err
+		}
+	}
+	return writeStats(w, ComputeStats(diff), f.comment)
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += n
+	return n, err
+}
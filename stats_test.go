@@ -0,0 +1,108 @@
+package code
+
+import (
+	"code/helpers"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComputeStats(t *testing.T) {
+	tests := []struct {
+		name           string
+		data1          map[string]interface{}
+		data2          map[string]interface{}
+		want           DiffStats
+		wantSimilarity float64
+	}{
+		{
+			name:           "empty documents",
+			data1:          map[string]interface{}{},
+			data2:          map[string]interface{}{},
+			want:           DiffStats{},
+			wantSimilarity: 100,
+		},
+		{
+			name:           "flat changes",
+			data1:          map[string]interface{}{"a": 1, "b": 2, "c": 3},
+			data2:          map[string]interface{}{"a": 1, "b": 20, "d": 4},
+			want:           DiffStats{Added: 1, Removed: 1, Changed: 1, Unchanged: 1, Sections: 4, Affected: 3},
+			wantSimilarity: 25,
+		},
+		{
+			name: "nested sections count leaves",
+			data1: map[string]interface{}{
+				"db":   map[string]interface{}{"host": "a", "port": 1},
+				"same": map[string]interface{}{"x": 1, "y": 2},
+			},
+			data2: map[string]interface{}{
+				"db":    map[string]interface{}{"host": "b", "port": 1},
+				"same":  map[string]interface{}{"x": 1, "y": 2},
+				"extra": map[string]interface{}{"a": 1, "b": map[string]interface{}{"c": 2}},
+			},
+			want:           DiffStats{Added: 2, Changed: 1, Unchanged: 3, Sections: 3, Affected: 2},
+			wantSimilarity: 50,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ComputeStats(computeDiff(tt.data1, tt.data2))
+			assert.Equal(t, tt.want, got)
+			assert.InDelta(t, tt.wantSimilarity, got.Similarity(), 0.001)
+		})
+	}
+}
+
+func TestGenDiffStatAndSummary(t *testing.T) {
+	file1 := helpers.CreateTempJSON(t, `{"a": 1, "b": 2}`)
+	file2 := helpers.CreateTempJSON(t, `{"a": 1, "b": 3}`)
+	stats := "added: 0\nremoved: 0\nchanged: 1\nunchanged: 1\nsections affected: 1 of 2\nsimilarity: 50.0%"
+
+	got, err := GenDiff(file1, file2, "stat")
+	require.NoError(t, err)
+	assert.Equal(t, stats, got)
+
+	opts := Options{Format: "stylish", FormatOptions: FormatOptions{Summary: true}}
+	formatter, err := NewFormatterWithOptions(opts.Format, opts.FormatOptions)
+	require.NoError(t, err)
+	got = formatter.Format(computeDiff(
+		map[string]interface{}{"a": 1, "b": 2},
+		map[string]interface{}{"a": 1, "b": 3},
+	))
+	assert.Equal(t, "{\n    a: 1\n  - b: 2\n  + b: 3\n}\n\n"+stats, got)
+}
+
+func TestSummaryFormats(t *testing.T) {
+	changed := computeDiff(map[string]interface{}{"a": float64(1)}, map[string]interface{}{"a": float64(2)})
+	same := computeDiff(map[string]interface{}{"a": float64(1)}, map[string]interface{}{"a": float64(1)})
+	stats := func(prefix string, changed, unchanged int) string {
+		return fmt.Sprintf("%[1]sadded: 0\n%[1]sremoved: 0\n%[1]schanged: %[2]d\n%[1]sunchanged: %[3]d\n"+
+			"%[1]ssections affected: %[2]d of 1\n%[1]ssimilarity: %.1[4]f%%", prefix, changed, unchanged, float64(unchanged)*100)
+	}
+
+	tests := []struct {
+		format string
+		diff   []DiffEntry
+		want   string
+	}{
+		{format: "sql", diff: changed, want: "UPDATE \"config\" SET \"value\" = '2' WHERE \"key\" = 'a';\n\n" + stats("-- ", 1, 0)},
+		{format: "jq", diff: changed, want: "setpath([\"a\"]; 2)\n\n" + stats("# ", 1, 0)},
+		{format: "name-only", diff: same, want: stats("", 0, 1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			formatter, err := NewFormatterWithOptions(tt.format, FormatOptions{Summary: true})
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, formatter.Format(tt.diff))
+		})
+	}
+
+	formatter, err := NewFormatterWithOptions("dot", FormatOptions{Summary: true})
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(formatter.Format(changed), "}\n\n"+stats("// ", 1, 0)))
+}